@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2"
+)
+
+// limits bound what one request may ask of the engine; a zero field is
+// unlimited. Oversized input is refused with 413, over-broad schemas with
+// 422, both with a {"detail": ...} body.
+type limits struct {
+	bodyBytes int64 // request body size
+	texts     int   // texts per request
+	textChars int   // characters (code points) per text
+	labels    int   // labels or fields per task; relation types per schema
+	tasks     int   // tasks per request, across a schema's passes
+}
+
+// checkTexts enforces the per-request text count and per-text length.
+func (l limits) checkTexts(texts []string) *httpError {
+	if l.texts > 0 && len(texts) > l.texts {
+		return &httpError{http.StatusRequestEntityTooLarge, fmt.Sprintf("request has %d texts; the limit is %d", len(texts), l.texts)}
+	}
+	if l.textChars > 0 {
+		for i, t := range texts {
+			if n := utf8.RuneCountInString(t); n > l.textChars {
+				return &httpError{http.StatusRequestEntityTooLarge, fmt.Sprintf("text %d has %d characters; the limit is %d", i, n, l.textChars)}
+			}
+		}
+	}
+	return nil
+}
+
+// checkTasks enforces the task count and the labels per task.
+func (l limits) checkTasks(tasks []gliner2.Task) *httpError {
+	if l.tasks > 0 && len(tasks) > l.tasks {
+		return &httpError{http.StatusUnprocessableEntity, fmt.Sprintf("schema has %d tasks; the limit is %d", len(tasks), l.tasks)}
+	}
+	if l.labels > 0 {
+		for _, t := range tasks {
+			if n := len(t.Labels) + len(t.Fields); n > l.labels {
+				return &httpError{http.StatusUnprocessableEntity, fmt.Sprintf("%s task %q has %d labels; the limit is %d", t.Type, t.Name, n, l.labels)}
+			}
+		}
+	}
+	return nil
+}
+
+// checkRelationTypes applies the labels limit to a schema's relation types,
+// which runSchema expands into one task each, so checkTasks never sees them
+// together.
+func (l limits) checkRelationTypes(types []string) *httpError {
+	if l.labels > 0 && len(types) > l.labels {
+		return &httpError{http.StatusUnprocessableEntity, fmt.Sprintf("schema has %d relation types; the limit is %d", len(types), l.labels)}
+	}
+	return nil
+}
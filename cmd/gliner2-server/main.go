@@ -12,23 +12,64 @@
 //	           "include_spans": false, "format_results": true}
 //	  reply:  {"result": <task-shaped result>}
 //
+//	POST /gliner-2/{model}  (or "model" in the body) selects a named model from
+//	  the -models file; the -repo model is named by -model-name ("default")
+//
+//	POST /v1/extract[/{model}]  native API, not part of the Python contract:
+//	  body:   {"tasks": [gliner2.Task...], "documents": [{"id": "a", "text": "..."}],
+//	           "threshold": 0.5, "flat_ner": false, "model": "..."}
+//	  reply:  {"model": "<repo:variant>", "results": [{"id": "a", "result": <gliner2.Result>}]}
+//
+//	GET /health/live   200 while the process is up
+//	GET /health/ready  200 once the model is loaded and warmed up, 503 before
+//	GET /metrics       Prometheus metrics (requests, latencies, texts, entities)
+//
+//	POST /admin/reload [{"repo": "...", "variant": "..."}]
+//	  loads the model (default: the current one) in the background, swaps it in
+//	  once warm and closes the old engine after in-flight requests drain; SIGHUP
+//	  reloads the current model. Every /gliner-2 reply carries the serving model
+//	  in an X-GLiNER2-Model header. Requires -admin-key in X-API-Key; without
+//	  -admin-key the endpoint is disabled (403). An old engine still busy after
+//	  -shutdown-timeout is closed anyway.
+//
+//	-queue-depth / -queue-timeout bound the requests each model holds: beyond
+//	  them a request gets 429 with Retry-After; while the model loads, 503
+//
+//	-max-body-bytes, -max-texts and -max-text-chars bound the input (413);
+//	  -max-labels and -max-tasks bound the schema (422)
+//
+//	-trace-exporter otlp|stdout (GLINER2_TRACE_EXPORTER or OTEL_TRACES_EXPORTER)
+//	  traces requests with OpenTelemetry, continuing incoming W3C trace context
+//
+//	SIGTERM/SIGINT flips /health/ready to 503, stops accepting connections,
+//	  drains in-flight requests for up to -shutdown-timeout and closes the engines
+//
 // Supported tasks: extract_entities, classify_text, extract_relations, schema.
 // extract_json / structured extraction is NOT supported by the ONNX engine and
 // returns HTTP 422 with a {"detail": ...} body (matching the client's error path).
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/soundprediction/go-gline-rs/pkg/gliner2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 func main() {
@@ -38,10 +79,39 @@ func main() {
 		variant    = flag.String("variant", envOr("GLINER2_VARIANT", "fp32_v2"), "model variant subfolder (e.g. fp32_v2, fp16_v2); empty for repo root")
 		modelType  = flag.String("model-type", envOr("GLINER2_MODEL_TYPE", "huggingface"), "model type: huggingface or pytorch")
 		apiKey     = flag.String("api-key", firstEnv("GLINER2_API_KEY", "PIONEER_API_KEY"), "if set, require this key in the X-API-Key header")
+		adminKey   = flag.String("admin-key", os.Getenv("GLINER2_ADMIN_KEY"), "key for /admin/reload; the endpoint is disabled without it")
 		requireGPU = flag.Bool("require-gpu", envBool("GLINER2_REQUIRE_GPU"), "fail startup unless ONNXRuntime exposes CUDAExecutionProvider")
+		warmup     = flag.Bool("warmup", !envBool("GLINER2_NO_WARMUP"), "run dummy inferences before reporting ready")
+		models     = flag.String("models", os.Getenv("GLINER2_MODELS"), "JSON file of extra named models, selectable per request and loaded on first use")
+		budgetMB   = flag.Int64("models-budget-mb", envInt64("GLINER2_MODELS_BUDGET_MB"), "evict idle named models beyond this estimated memory (0: unlimited)")
+		defName    = flag.String("model-name", envOr("GLINER2_MODEL_NAME", "default"), "name that selects the -repo model in requests")
+		queueDepth = flag.Int("queue-depth", int(envInt64Or("GLINER2_QUEUE_DEPTH", 64)), "requests a model admits at once, running or waiting; more get 429 (0: unbounded)")
+		stopWait   = flag.Duration("shutdown-timeout", envDurationOr("GLINER2_SHUTDOWN_TIMEOUT", 30*time.Second), "on SIGTERM/SIGINT, or when a reload replaces a model, how long to let in-flight requests finish")
+		traceExp   = flag.String("trace-exporter", envOr("GLINER2_TRACE_EXPORTER", os.Getenv("OTEL_TRACES_EXPORTER")), "OpenTelemetry trace exporter: otlp, stdout, or empty/none for no tracing")
+		maxBody    = flag.Int64("max-body-bytes", envInt64Or("GLINER2_MAX_BODY_BYTES", 10<<20), "largest request body accepted, else 413 (0: unlimited)")
+		maxTexts   = flag.Int("max-texts", int(envInt64Or("GLINER2_MAX_TEXTS", 256)), "most texts per request, else 413 (0: unlimited)")
+		maxChars   = flag.Int("max-text-chars", int(envInt64Or("GLINER2_MAX_TEXT_CHARS", 100_000)), "most characters per text, else 413 (0: unlimited)")
+		maxLabels  = flag.Int("max-labels", int(envInt64Or("GLINER2_MAX_LABELS", 100)), "most labels per task or relation types per schema, else 422 (0: unlimited)")
+		maxTasks   = flag.Int("max-tasks", int(envInt64Or("GLINER2_MAX_TASKS", 64)), "most tasks per request schema, else 422 (0: unlimited)")
+		queueWait  = flag.Duration("queue-timeout", envDurationOr("GLINER2_QUEUE_TIMEOUT", 30*time.Second), "longest an admitted request waits for the model before a 429 (0: no limit)")
 	)
 	flag.Parse()
 
+	if *traceExp != "" && *traceExp != "none" {
+		stopTracing, err := setupTracing(context.Background(), *traceExp)
+		if err != nil {
+			log.Fatalf("tracing: %v", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := stopTracing(ctx); err != nil {
+				log.Printf("flush traces: %v", err)
+			}
+		}()
+		log.Printf("tracing with the %s exporter", *traceExp)
+	}
+
 	mt := gliner2.ModelTypeHuggingFace
 	if *modelType == "pytorch" {
 		mt = gliner2.ModelTypePyTorch
@@ -56,29 +126,141 @@ func main() {
 		log.Fatalf("GLINER2_REQUIRE_GPU is set but CUDAExecutionProvider is unavailable; ORT_DYLIB_PATH=%q", os.Getenv("ORT_DYLIB_PATH"))
 	}
 
-	log.Printf("loading model %q (variant %q)…", *repo, *variant)
-	eng, err := gliner2.New(*repo, *variant, mt)
-	if err != nil {
-		log.Fatalf("load model: %v", err)
+	srv := &server{apiKey: *apiKey, adminKey: *adminKey, repo: *repo, variant: *variant, defaultName: *defName,
+		queueDepth: *queueDepth, queueTimeout: *queueWait, drainTimeout: *stopWait,
+		limits: limits{bodyBytes: *maxBody, texts: *maxTexts, textChars: *maxChars, labels: *maxLabels, tasks: *maxTasks}}
+	srv.load = func(repo, variant string) (gliner2.Extractor, error) {
+		return loadEngine(repo, variant, mt, *warmup)
 	}
-	defer eng.Close()
-	log.Printf("model loaded")
+	if *models != "" {
+		cfgs, err := loadModels(*models, *warmup)
+		if err != nil {
+			log.Fatalf("load -models: %v", err)
+		}
+		srv.reg = gliner2.NewRegistryWithLoader(cfgs, *budgetMB<<20, timeLoads(func(cfg gliner2.ModelConfig) (gliner2.Extractor, error) {
+			eng, err := loadEngine(cfg.Repo, cfg.Variant, cfg.ModelType, cfg.Warmup)
+			if err != nil {
+				return nil, err
+			}
+			return eng, nil
+		}))
+		srv.named = make(map[string]*model, len(cfgs))
+		for name, cfg := range cfgs {
+			srv.named[name] = srv.newModel(&model{reg: srv.reg, name: name, repo: cfg.Repo, variant: cfg.Variant})
+		}
+		log.Printf("serving %d named model(s) from %s", len(cfgs), *models)
+	}
+	mux := srv.routes()
+
+	// Load (and warm up) the model in the background so liveness probes pass
+	// while weights download; readiness flips only once the engine is warm.
+	srv.startReload(*repo, *variant)
+	srv.reloadOnSIGHUP()
+
+	hs := &http.Server{Addr: *addr, Handler: mux}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	served := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %s", *addr)
+		served <- hs.ListenAndServe()
+	}()
+
+	select {
+	case err := <-served:
+		log.Fatalf("server: %v", err)
+	case sig := <-stop:
+		log.Printf("%s: draining in-flight requests (up to %s)", sig, *stopWait)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *stopWait)
+	defer cancel()
+	if err := srv.shutdown(ctx, hs); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+	log.Printf("stopped")
+}
+
+// shutdown stops accepting requests and reports not ready, lets in-flight
+// requests finish until ctx is done, then closes every engine. Engines still
+// busy when ctx expires are left open for the process exit to reclaim.
+func (s *server) shutdown(ctx context.Context, hs *http.Server) error {
+	s.stopping.Store(true)
+	s.ready.Store(false)
+	err := hs.Shutdown(ctx)
+	if cerr := s.closeModels(ctx); err == nil {
+		err = cerr
+	}
+	return err
+}
 
-	srv := &server{eng: eng, apiKey: *apiKey}
+func (s *server) routes() *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/gliner-2", srv.handleExtract)
-	mux.HandleFunc("/health", srv.handleHealth)
+	mux.HandleFunc("/gliner-2", s.handleExtract)
+	mux.HandleFunc("/gliner-2/{model}", s.handleExtract)
+	mux.HandleFunc("/v1/extract", s.handleV1Extract)
+	mux.HandleFunc("/v1/extract/{model}", s.handleV1Extract)
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/health/live", s.handleLive)
+	mux.HandleFunc("/health/ready", s.handleReady)
+	mux.HandleFunc("/admin/reload", s.handleReload)
+	mux.Handle("GET /metrics", metricsHandler())
+	return mux
+}
 
-	log.Printf("listening on %s", *addr)
-	if err := http.ListenAndServe(*addr, mux); err != nil {
-		log.Fatalf("server: %v", err)
+// loadEngine loads the model and, if warmup is set, runs dummy inferences so
+// the first real request does not pay for ONNX session initialization.
+func loadEngine(repo, variant string, mt gliner2.ModelType, warmup bool) (*gliner2.Engine, error) {
+	log.Printf("loading model %q (variant %q)…", repo, variant)
+	start := time.Now()
+	eng, err := gliner2.New(repo, variant, mt)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("model loaded in %s", time.Since(start).Round(time.Millisecond))
+	if warmup {
+		start = time.Now()
+		if err := eng.Warmup(context.Background(), nil); err != nil {
+			eng.Close()
+			return nil, err
+		}
+		log.Printf("warmup done in %s", time.Since(start).Round(time.Millisecond))
 	}
+	return eng, nil
 }
 
 type server struct {
-	mu     sync.Mutex // Engine is not safe for concurrent Extract calls
-	eng    *gliner2.Engine
-	apiKey string
+	apiKey   string
+	adminKey string // for /admin/reload; disabled when empty
+
+	// Startup model; load builds (and warms up) the extractor for a
+	// repo/variant: loadEngine in production, a gliner2test.Fake in tests.
+	repo    string
+	variant string
+	load    func(repo, variant string) (gliner2.Extractor, error)
+
+	model atomic.Pointer[model] // current model; nil until the first load
+	ready atomic.Bool           // set once the first model is loaded and warm
+
+	stopping atomic.Bool // set on SIGTERM/SIGINT; no model is loaded after it
+
+	// Named models from -models, selected by a request's "model" field or
+	// /gliner-2/{model}; defaultName selects the model above.
+	reg         *gliner2.Registry
+	named       map[string]*model
+	defaultName string
+
+	drainTimeout time.Duration // how long a replaced model may drain; 0: no limit
+
+	reloadMu  sync.Mutex
+	reloading bool
+	reloadErr string     // last failed load, cleared by the next success
+	swapMu    sync.Mutex // orders model swaps against shutdown
+
+	// Per-model request queue (see requestQueue); off when queueDepth is 0.
+	queueDepth   int
+	queueTimeout time.Duration
+
+	limits limits // per-request input limits; the zero value is unlimited
 }
 
 // apiRequest mirrors the payload built by gliner2/api_client.py._make_request.
@@ -91,13 +273,42 @@ type apiRequest struct {
 	IncludeConfidence bool            `json:"include_confidence"`
 	IncludeSpans      bool            `json:"include_spans"`
 	FormatResults     *bool           `json:"format_results"`
+	Model             string          `json:"model"`    // extension: named model, see -models
+	FlatNER           bool            `json:"flat_ner"` // extension: forbid overlapping entity spans
 }
 
 func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "model_loaded": true})
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "model_loaded": s.ready.Load(), "model": s.modelStatus(), "models": s.namedStatus(), "native": gliner2.InitStatus()})
+}
+
+// handleLive is the liveness probe: the process is up and serving HTTP.
+func (s *server) handleLive(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok"})
 }
 
-func (s *server) handleExtract(w http.ResponseWriter, r *http.Request) {
+// handleReady is the readiness probe: 200 once the model is loaded and warm,
+// 503 before that and while shutting down.
+func (s *server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if s.stopping.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "shutting_down"})
+		return
+	}
+	if !s.ready.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "loading"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ready"})
+}
+
+func (s *server) handleExtract(rw http.ResponseWriter, r *http.Request) {
+	w := &statusWriter{ResponseWriter: rw}
+	var req apiRequest
+	ctx, span := startRequestSpan(r)
+	defer func() {
+		observeRequest(req.Task, w.status())
+		endRequestSpan(span, req.Task, w.status())
+	}()
+
 	if r.Method != http.MethodPost {
 		writeDetail(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
@@ -106,33 +317,44 @@ func (s *server) handleExtract(w http.ResponseWriter, r *http.Request) {
 		writeDetail(w, http.StatusUnauthorized, "Invalid or expired API key")
 		return
 	}
+	if s.limits.bodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.limits.bodyBytes)
+	}
+	texts, batch, herr := s.decodeRequest(ctx, r, &req)
+	if herr != nil {
+		writeDetail(w, herr.code, herr.msg)
+		return
+	}
+	name := req.Model
+	if p := r.PathValue("model"); p != "" {
+		name = p
+	}
 
-	var req apiRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeDetail(w, http.StatusUnprocessableEntity, fmt.Sprintf("invalid request body: %v", err))
+	m := s.acquireOrReply(w, name)
+	if m == nil {
 		return
 	}
+	defer s.releaseModel(m)
 
 	threshold := float32(0.5)
 	if req.Threshold != nil {
 		threshold = float32(*req.Threshold)
 	}
 
-	// text is either a single string or a list of strings (batch).
-	texts, batch, err := decodeText(req.Text)
+	leave, err := m.enterQueue(ctx)
 	if err != nil {
-		writeDetail(w, http.StatusUnprocessableEntity, err.Error())
+		writeBusy(w, err)
 		return
 	}
+	defer leave()
 
-	results := make([]any, 0, len(texts))
+	results, herr := m.runTexts(ctx, texts, &req, threshold)
+	if herr != nil {
+		writeDetail(w, herr.code, herr.msg)
+		return
+	}
 	for _, text := range texts {
-		out, herr := s.runTask(text, &req, threshold)
-		if herr != nil {
-			writeDetail(w, herr.code, herr.msg)
-			return
-		}
-		results = append(results, out)
+		observeText(text)
 	}
 
 	// Single string in → single object out; list in → list out (matches the client,
@@ -141,7 +363,80 @@ func (s *server) handleExtract(w http.ResponseWriter, r *http.Request) {
 	if batch {
 		result = results
 	}
+	_, espan := tracer.Start(ctx, "response.encode")
 	writeJSON(w, http.StatusOK, map[string]any{"result": result})
+	espan.End()
+}
+
+// decodeRequest reads the JSON body into req and returns its texts: text is
+// either a single string or a list of strings (batch). Bodies, text counts and
+// texts over the limits are refused with 413.
+func (s *server) decodeRequest(ctx context.Context, r *http.Request, req *apiRequest) (texts []string, batch bool, herr *httpError) {
+	_, span := tracer.Start(ctx, "request.decode")
+	defer func() {
+		if herr != nil {
+			span.SetStatus(codes.Error, herr.msg)
+		}
+		span.End()
+	}()
+	if herr := decodeBody(r, req); herr != nil {
+		return nil, false, herr
+	}
+	texts, batch, err := decodeText(req.Text)
+	if err != nil {
+		return nil, false, &httpError{http.StatusUnprocessableEntity, err.Error()}
+	}
+	span.SetAttributes(attribute.Int("gliner2.texts", len(texts)))
+	return texts, batch, s.limits.checkTexts(texts)
+}
+
+// runTexts runs the task over each text in turn, stopping at the first
+// failing text.
+func (m *model) runTexts(ctx context.Context, texts []string, req *apiRequest, threshold float32) ([]any, *httpError) {
+	results := make([]any, 0, len(texts))
+	for _, text := range texts {
+		out, herr := m.runTask(ctx, text, req, threshold)
+		if herr != nil {
+			return nil, herr
+		}
+		results = append(results, out)
+	}
+	return results, nil
+}
+
+// decodeBody decodes the JSON request body into v: 413 if it exceeds
+// -max-body-bytes, 422 if it is not valid JSON for v.
+func decodeBody(r *http.Request, v any) *httpError {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			return &httpError{http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooBig.Limit)}
+		}
+		return &httpError{http.StatusUnprocessableEntity, fmt.Sprintf("invalid request body: %v", err)}
+	}
+	return nil
+}
+
+// run checks tasks against the limits and runs them over text, reporting a
+// failure with extractErrorCode.
+func (m *model) run(ctx context.Context, text string, tasks []gliner2.Task, threshold float32, flatNER bool) (*gliner2.Result, *httpError) {
+	if herr := m.limits.checkTasks(tasks); herr != nil {
+		return nil, herr
+	}
+	res, err := m.extract(ctx, text, tasks, threshold, flatNER)
+	if err != nil {
+		return nil, &httpError{extractErrorCode(err), err.Error()}
+	}
+	return res, nil
+}
+
+// extractErrorCode is the status for a failed extraction: 422 when the engine
+// rejected the tasks as invalid or unsupported, 500 for an engine failure.
+func extractErrorCode(err error) int {
+	if errors.Is(err, gliner2.ErrInvalidTasks) || errors.Is(err, gliner2.ErrUnsupported) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
 }
 
 type httpError struct {
@@ -151,16 +446,16 @@ type httpError struct {
 
 // runTask dispatches one (text, request) to the engine and formats the result to
 // match the local GLiNER2 library's output shapes.
-func (s *server) runTask(text string, req *apiRequest, threshold float32) (any, *httpError) {
+func (m *model) runTask(ctx context.Context, text string, req *apiRequest, threshold float32) (any, *httpError) {
 	switch req.Task {
 	case "extract_entities":
 		var labels []string
 		if err := json.Unmarshal(req.Schema, &labels); err != nil {
 			return nil, &httpError{http.StatusUnprocessableEntity, "extract_entities: schema must be a list of entity labels"}
 		}
-		res, err := s.extract(text, []gliner2.Task{gliner2.Entities(labels...)}, threshold)
-		if err != nil {
-			return nil, &httpError{http.StatusInternalServerError, err.Error()}
+		res, herr := m.run(ctx, text, []gliner2.Task{gliner2.Entities(labels...)}, threshold, req.FlatNER)
+		if herr != nil {
+			return nil, herr
 		}
 		return map[string]any{"entities": formatEntities(res, labels, req.IncludeConfidence, req.IncludeSpans)}, nil
 
@@ -172,18 +467,18 @@ func (s *server) runTask(text string, req *apiRequest, threshold float32) (any,
 		if err := json.Unmarshal(req.Schema, &sc); err != nil || len(sc.Categories) == 0 {
 			return nil, &httpError{http.StatusUnprocessableEntity, "classify_text: schema must be {\"categories\": [labels]}"}
 		}
-		res, err := s.extract(text, []gliner2.Task{gliner2.Classifications("categories", sc.Categories...)}, threshold)
-		if err != nil {
-			return nil, &httpError{http.StatusInternalServerError, err.Error()}
+		res, herr := m.run(ctx, text, []gliner2.Task{gliner2.Classifications("categories", sc.Categories...)}, threshold, req.FlatNER)
+		if herr != nil {
+			return nil, herr
 		}
 		return map[string]any{"classification": topClassification(res, "categories", req.IncludeConfidence)}, nil
 
 	case "extract_relations":
 		// schema is built client-side as {"relations": [...], ...}; handle via schema path.
-		return s.runSchema(text, req.Schema, threshold, req)
+		return m.runSchema(ctx, text, req.Schema, threshold, req)
 
 	case "schema":
-		return s.runSchema(text, req.Schema, threshold, req)
+		return m.runSchema(ctx, text, req.Schema, threshold, req)
 
 	case "extract_json":
 		// schema = {structure_name: [field_spec, ...]}, field_spec is a string
@@ -196,9 +491,9 @@ func (s *server) runTask(text string, req *apiRequest, threshold float32) (any,
 		if herr != nil {
 			return nil, herr
 		}
-		res, err := s.extract(text, tasks, threshold)
-		if err != nil {
-			return nil, &httpError{http.StatusInternalServerError, err.Error()}
+		res, herr := m.run(ctx, text, tasks, threshold, req.FlatNER)
+		if herr != nil {
+			return nil, herr
 		}
 		out := map[string]any{}
 		for _, st := range res.Structures {
@@ -219,69 +514,111 @@ type schemaDoc struct {
 	Structures      map[string]json.RawMessage `json:"structures"`
 }
 
-func (s *server) runSchema(text string, raw json.RawMessage, threshold float32, req *apiRequest) (any, *httpError) {
+func (m *model) runSchema(ctx context.Context, text string, raw json.RawMessage, threshold float32, req *apiRequest) (any, *httpError) {
 	var doc schemaDoc
 	if err := json.Unmarshal(raw, &doc); err != nil {
 		return nil, &httpError{http.StatusUnprocessableEntity, "schema must be an object"}
 	}
-	out := map[string]any{}
 
-	// Structures.
+	// All tasks share one forward pass, except classifications with their own
+	// cls_threshold, which need a pass at that threshold.
+	var passes []*schemaPass
+	passAt := func(th float32) *schemaPass {
+		for _, p := range passes {
+			if p.threshold == th {
+				return p
+			}
+		}
+		p := &schemaPass{threshold: th}
+		passes = append(passes, p)
+		return p
+	}
+
+	var structs, ents, rels *schemaPass
 	if len(doc.Structures) > 0 {
 		tasks, herr := buildStructureTasks(doc.Structures)
 		if herr != nil {
 			return nil, herr
 		}
-		res, err := s.extract(text, tasks, threshold)
-		if err != nil {
-			return nil, &httpError{http.StatusInternalServerError, err.Error()}
-		}
-		for _, st := range res.Structures {
-			out[st.Name] = st.Instances
-		}
+		structs = passAt(threshold)
+		structs.tasks = append(structs.tasks, tasks...)
 	}
 
-	// Entities.
 	entityLabels := decodeLabelList(doc.Entities)
 	if len(entityLabels) > 0 {
-		res, err := s.extract(text, []gliner2.Task{gliner2.Entities(entityLabels...)}, threshold)
-		if err != nil {
-			return nil, &httpError{http.StatusInternalServerError, err.Error()}
-		}
-		out["entities"] = formatEntities(res, entityLabels, req.IncludeConfidence, req.IncludeSpans)
+		ents = passAt(threshold)
+		ents.tasks = append(ents.tasks, gliner2.Entities(entityLabels...))
 	}
 
-	// Classifications: one task per name; merge each as {taskName: value}.
-	for task, cfgRaw := range doc.Classifications {
-		labels, multi, clsThresh := decodeClassification(cfgRaw, threshold)
+	clsNames := make([]string, 0, len(doc.Classifications))
+	for task := range doc.Classifications {
+		clsNames = append(clsNames, task)
+	}
+	sort.Strings(clsNames)
+	var classes []schemaClassification
+	for _, task := range clsNames {
+		labels, multi, clsThresh := decodeClassification(doc.Classifications[task], threshold)
 		if len(labels) == 0 {
 			continue
 		}
-		res, err := s.extract(text, []gliner2.Task{gliner2.Classifications(task, labels...)}, clsThresh)
-		if err != nil {
-			return nil, &httpError{http.StatusInternalServerError, err.Error()}
-		}
-		if multi {
-			out[task] = multiClassification(res, task, clsThresh, req.IncludeConfidence)
-		} else {
-			out[task] = topClassification(res, task, req.IncludeConfidence)
-		}
+		p := passAt(clsThresh)
+		p.tasks = append(p.tasks, gliner2.Classifications(task, labels...))
+		classes = append(classes, schemaClassification{name: task, multi: multi, threshold: clsThresh, pass: p})
 	}
 
-	// Relations: run per relation type so pairs are attributable to their type.
 	relTypes := decodeLabelList(doc.Relations)
+	if herr := m.limits.checkRelationTypes(relTypes); herr != nil {
+		return nil, herr
+	}
 	if len(relTypes) > 0 {
+		rels = passAt(threshold)
+		for _, rt := range relTypes {
+			rels.tasks = append(rels.tasks, gliner2.Relations(rt, "head", "tail"))
+		}
+	}
+
+	var all []gliner2.Task
+	for _, p := range passes {
+		all = append(all, p.tasks...)
+	}
+	if herr := m.limits.checkTasks(all); herr != nil {
+		return nil, herr
+	}
+	for _, p := range passes {
+		res, herr := m.run(ctx, text, p.tasks, p.threshold, req.FlatNER)
+		if herr != nil {
+			return nil, herr
+		}
+		p.res = res
+	}
+
+	// Split the combined results back into the per-task shapes.
+	out := map[string]any{}
+	if structs != nil {
+		for _, st := range structs.res.Structures {
+			out[st.Name] = st.Instances
+		}
+	}
+	if ents != nil {
+		out["entities"] = formatEntities(ents.res, entityLabels, req.IncludeConfidence, req.IncludeSpans)
+	}
+	for _, c := range classes {
+		if c.multi {
+			out[c.name] = multiClassification(c.pass.res, c.name, c.threshold, req.IncludeConfidence)
+		} else {
+			out[c.name] = topClassification(c.pass.res, c.name, req.IncludeConfidence)
+		}
+	}
+	if rels != nil {
 		rel := map[string]any{}
 		for _, rt := range relTypes {
-			res, err := s.extract(text, []gliner2.Task{gliner2.Relations(rt, "head", "tail")}, threshold)
-			if err != nil {
-				return nil, &httpError{http.StatusInternalServerError, err.Error()}
-			}
-			pairs := make([][]string, 0, len(res.Relations))
-			for _, r := range res.Relations {
-				pairs = append(pairs, []string{r.Head.Text, r.Tail.Text})
+			pairs := [][]string{} // present even when empty, matching the library
+			for _, r := range rels.res.Relations {
+				if r.RelationType == rt {
+					pairs = append(pairs, []string{r.Head.Text, r.Tail.Text})
+				}
 			}
-			rel[rt] = pairs // present even when empty, matching the library
+			rel[rt] = pairs
 		}
 		out["relation_extraction"] = rel
 	}
@@ -292,10 +629,21 @@ func (s *server) runSchema(text string, raw json.RawMessage, threshold float32,
 	return out, nil
 }
 
-func (s *server) extract(text string, tasks []gliner2.Task, threshold float32) (*gliner2.Result, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.eng.Extract(text, tasks, threshold, false)
+// schemaPass is one forward pass of a schema request: the tasks that run at
+// threshold, and their combined result.
+type schemaPass struct {
+	threshold float32
+	tasks     []gliner2.Task
+	res       *gliner2.Result
+}
+
+// schemaClassification is a classification task of a schema request and the
+// pass that runs it.
+type schemaClassification struct {
+	name      string
+	multi     bool
+	threshold float32
+	pass      *schemaPass
 }
 
 // formatEntities groups entities by label (all requested labels present, possibly
@@ -528,6 +876,18 @@ func writeDetail(w http.ResponseWriter, code int, msg string) {
 	writeJSON(w, code, map[string]any{"detail": msg})
 }
 
+// writeBusy answers a request the queue turned away: 429 with Retry-After,
+// or 503 if the client went away first.
+func writeBusy(w http.ResponseWriter, err error) {
+	if !errors.Is(err, errQueueFull) && !errors.Is(err, errQueueTimeout) {
+		writeDetail(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	queueRejectedTotal.Inc()
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterBusy))
+	writeDetail(w, http.StatusTooManyRequests, err.Error())
+}
+
 func envOr(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -544,6 +904,25 @@ func envBool(key string) bool {
 	}
 }
 
+func envInt64(key string) int64 {
+	n, _ := strconv.ParseInt(os.Getenv(key), 10, 64)
+	return n
+}
+
+func envInt64Or(key string, def int64) int64 {
+	if n, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil {
+		return n
+	}
+	return def
+}
+
+func envDurationOr(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return d
+	}
+	return def
+}
+
 func firstEnv(keys ...string) string {
 	for _, k := range keys {
 		if v := os.Getenv(k); v != "" {
@@ -0,0 +1,921 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2"
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2/gliner2test"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTestServer returns a server whose default model is served by f, not yet
+// loaded; call s.swapModel to load it.
+func newTestServer(f *gliner2test.Fake) *server {
+	return &server{
+		repo:        "org/model",
+		variant:     "fp32_v2",
+		defaultName: "default",
+		load:        func(string, string) (gliner2.Extractor, error) { return f, nil },
+	}
+}
+
+func do(t *testing.T, h http.Handler, method, path, body string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("%s %s: decode %q: %v", method, path, rec.Body.String(), err)
+	}
+	return rec, out
+}
+
+const entitiesReq = `{"task": "extract_entities", "text": "Mario Rossi works at Apple.", "schema": ["person", "company"]}`
+
+func TestExtractEntities(t *testing.T) {
+	f := gliner2test.New().AddEntity("person", `Mario Rossi`).AddEntity("company", `Apple`)
+	s := newTestServer(f)
+	h := s.routes()
+
+	if rec, _ := do(t, h, http.MethodGet, "/health/ready", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("ready before load = %d, want 503", rec.Code)
+	}
+	if rec, _ := do(t, h, http.MethodPost, "/gliner-2", entitiesReq); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("extract before load = %d, want 503", rec.Code)
+	}
+
+	if err := s.swapModel(s.repo, s.variant); err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ := do(t, h, http.MethodGet, "/health/ready", ""); rec.Code != http.StatusOK {
+		t.Errorf("ready after load = %d, want 200", rec.Code)
+	}
+	rec, out := do(t, h, http.MethodPost, "/gliner-2", entitiesReq)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("extract = %d %v", rec.Code, out)
+	}
+	if got := rec.Header().Get("X-GLiNER2-Model"); got != "org/model:fp32_v2" {
+		t.Errorf("X-GLiNER2-Model = %q", got)
+	}
+	b, _ := json.Marshal(out["result"])
+	if want := `{"entities":{"company":["Apple"],"person":["Mario Rossi"]}}`; string(b) != want {
+		t.Errorf("result = %s, want %s", b, want)
+	}
+}
+
+func TestReloadClosesOldModel(t *testing.T) {
+	old := gliner2test.New()
+	s := newTestServer(old)
+	if err := s.swapModel(s.repo, s.variant); err != nil {
+		t.Fatal(err)
+	}
+	next := gliner2test.New().AddEntity("person", `Mario Rossi`)
+	s.load = func(string, string) (gliner2.Extractor, error) { return next, nil }
+	if err := s.swapModel("org/other", ""); err != nil {
+		t.Fatal(err)
+	}
+	if !old.Closed() || next.Closed() {
+		t.Errorf("after swap: old closed = %v, new closed = %v", old.Closed(), next.Closed())
+	}
+	rec, _ := do(t, s.routes(), http.MethodPost, "/gliner-2", entitiesReq)
+	if got := rec.Header().Get("X-GLiNER2-Model"); got != "org/other" {
+		t.Errorf("X-GLiNER2-Model = %q, want org/other", got)
+	}
+}
+
+// TestReloadDrainTimeout verifies a reload closes the old engine once
+// drainTimeout passes, even while a request still holds it.
+func TestReloadDrainTimeout(t *testing.T) {
+	g := gated{Fake: gliner2test.New().AddEntity("person", `Mario Rossi`), started: make(chan struct{}, 1), release: make(chan struct{})}
+	s := newTestServer(g.Fake)
+	s.load = func(string, string) (gliner2.Extractor, error) { return g, nil }
+	s.drainTimeout = 20 * time.Millisecond
+	if err := s.swapModel(s.repo, s.variant); err != nil {
+		t.Fatal(err)
+	}
+	h := s.routes()
+
+	codes := make(chan int, 1)
+	go func() {
+		rec, _ := do(t, h, http.MethodPost, "/gliner-2", entitiesReq)
+		codes <- rec.Code
+	}()
+	<-g.started
+
+	s.load = func(string, string) (gliner2.Extractor, error) { return gliner2test.New(), nil }
+	swapped := make(chan error, 1)
+	go func() { swapped <- s.swapModel("org/other", "") }()
+	select {
+	case err := <-swapped:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload still waiting on a busy old model past drainTimeout")
+	}
+	if !g.Fake.Closed() {
+		t.Error("old engine left open after drainTimeout")
+	}
+	close(g.release)
+	<-codes
+}
+
+func TestReloadAuth(t *testing.T) {
+	tests := []struct {
+		name, apiKey, adminKey, header, body string
+		code                                 int
+	}{
+		{"no key, current model", "", "", "", ``, http.StatusForbidden},
+		{"no key, other repo", "", "", "", `{"repo": "evil/model"}`, http.StatusForbidden},
+		{"api key only", "k", "", "k", ``, http.StatusForbidden},
+		{"admin key", "k", "admin", "admin", `{"repo": "org/other"}`, http.StatusAccepted},
+		{"admin key, current model", "", "admin", "admin", ``, http.StatusAccepted},
+		{"admin key missing", "", "admin", "", ``, http.StatusUnauthorized},
+		{"admin key prefix", "", "admin", "adm", ``, http.StatusUnauthorized},
+		{"api key is not the admin key", "k", "admin", "k", ``, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(gliner2test.New())
+			s.apiKey, s.adminKey = tt.apiKey, tt.adminKey
+			req := httptest.NewRequest(http.MethodPost, "/admin/reload", strings.NewReader(tt.body))
+			if tt.header != "" {
+				req.Header.Set("X-API-Key", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			s.routes().ServeHTTP(rec, req)
+			if rec.Code != tt.code {
+				t.Errorf("code = %d %s, want %d", rec.Code, rec.Body, tt.code)
+			}
+			for s.modelStatus()["reloading"] == true {
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestNamedModels(t *testing.T) {
+	def := gliner2test.New()
+	named := gliner2test.New().AddEntity("person", `Mario Rossi`)
+	s := newTestServer(def)
+	cfgs := map[string]gliner2.ModelConfig{"legal": {Repo: "org/legal"}}
+	s.reg = gliner2.NewRegistryWithLoader(cfgs, 0, named.Loader())
+	s.named = map[string]*model{"legal": {reg: s.reg, name: "legal", repo: "org/legal"}}
+	if err := s.swapModel(s.repo, s.variant); err != nil {
+		t.Fatal(err)
+	}
+	h := s.routes()
+
+	for _, path := range []string{"/gliner-2/legal", "/gliner-2"} {
+		body := entitiesReq
+		if path == "/gliner-2" {
+			body = strings.Replace(body, `{`, `{"model": "legal", `, 1)
+		}
+		rec, out := do(t, h, http.MethodPost, path, body)
+		if rec.Code != http.StatusOK || rec.Header().Get("X-GLiNER2-Model") != "org/legal" {
+			t.Errorf("%s: %d %v (model %q)", path, rec.Code, out, rec.Header().Get("X-GLiNER2-Model"))
+		}
+	}
+	if rec, _ := do(t, h, http.MethodPost, "/gliner-2/nope", entitiesReq); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown model = %d, want 404", rec.Code)
+	}
+	if n := len(def.Calls()); n != 0 {
+		t.Errorf("default model got %d calls, want 0", n)
+	}
+
+	// While a named model loads, the request that started the load waits
+	// for it and the others get 503 with Retry-After.
+	release := make(chan struct{})
+	s.reg.Close()
+	slow := gliner2test.New().AddEntity("person", `Mario Rossi`)
+	s.reg = gliner2.NewRegistryWithLoader(cfgs, 0, func(cfg gliner2.ModelConfig) (gliner2.Extractor, error) {
+		<-release
+		return slow.Loader()(cfg)
+	})
+	s.named["legal"].reg = s.reg
+	first := make(chan int)
+	go func() {
+		rec, _ := do(t, h, http.MethodPost, "/gliner-2/legal", entitiesReq)
+		first <- rec.Code
+	}()
+	for !s.reg.Loading("legal") {
+		time.Sleep(time.Millisecond)
+	}
+	rec, _ := do(t, h, http.MethodPost, "/gliner-2/legal", entitiesReq)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("while loading = %d (Retry-After %q), want 503 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	close(release)
+	if code := <-first; code != http.StatusOK {
+		t.Errorf("request that started the load = %d, want 200", code)
+	}
+}
+
+// perTask runs each task of an Extract call as a separate call to the Fake
+// and merges the results: the one-pass-per-task behavior that schema requests
+// used to have.
+type perTask struct{ gliner2.Extractor }
+
+func (p perTask) Extract(text string, tasks []gliner2.Task, threshold float32, flatNER bool) (*gliner2.Result, error) {
+	merged := &gliner2.Result{}
+	for _, t := range tasks {
+		res, err := p.Extractor.Extract(text, []gliner2.Task{t}, threshold, flatNER)
+		if err != nil {
+			return nil, err
+		}
+		merged.Entities = append(merged.Entities, res.Entities...)
+		merged.Relations = append(merged.Relations, res.Relations...)
+		merged.Classifications = append(merged.Classifications, res.Classifications...)
+		merged.Structures = append(merged.Structures, res.Structures...)
+	}
+	return merged, nil
+}
+
+func TestSchemaSinglePass(t *testing.T) {
+	newFake := func() *gliner2test.Fake {
+		return gliner2test.New().
+			AddEntity("person", `Mario Rossi`).AddEntity("company", `Apple`).
+			AddClassification("sentiment", "positive", 0.9).AddClassification("sentiment", "negative", 0.1).
+			AddClassification("topic", "tech", 0.6).AddClassification("topic", "finance", 0.2).
+			AddRelation("works_at", "Mario Rossi", "Apple")
+	}
+	const req = `{"task": "schema", "text": "Mario Rossi works at Apple.", "include_confidence": true, "schema": {
+		"entities": ["person", "company"],
+		"classifications": {
+			"sentiment": ["positive", "negative"],
+			"topic": {"labels": ["tech", "finance"], "multi_label": true, "cls_threshold": 0.3}
+		},
+		"relations": ["works_at", "lives_in"]
+	}}`
+
+	run := func(s *server) map[string]any {
+		t.Helper()
+		if err := s.swapModel(s.repo, s.variant); err != nil {
+			t.Fatal(err)
+		}
+		rec, out := do(t, s.routes(), http.MethodPost, "/gliner-2", req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("extract = %d %v", rec.Code, out)
+		}
+		res, _ := out["result"].(map[string]any)
+		return res
+	}
+
+	combined := newFake()
+	got := run(newTestServer(combined))
+
+	separate := newFake()
+	s := newTestServer(separate)
+	s.load = func(string, string) (gliner2.Extractor, error) { return perTask{separate}, nil }
+	want := run(s)
+
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("combined pass = %s\nseparate passes = %s", gotJSON, wantJSON)
+	}
+	if len(got) != 4 {
+		t.Errorf("schema result = %s, want entities, sentiment, topic and relation_extraction", gotJSON)
+	}
+	// One pass at the request threshold, one at topic's cls_threshold.
+	if calls := combined.Calls(); len(calls) != 2 {
+		t.Errorf("forward passes = %d, want 2: %+v", len(calls), calls)
+	}
+	if len(separate.Calls()) != 5 {
+		t.Errorf("per-task passes = %d, want 5", len(separate.Calls()))
+	}
+	rel, _ := got["relation_extraction"].(map[string]any)
+	if works, _ := rel["works_at"].([]any); len(works) != 1 || rel["lives_in"] == nil {
+		t.Errorf("relation_extraction = %v, want one works_at pair and an empty lives_in", rel)
+	}
+}
+
+// TestSchemaPasses verifies which forward pass each task of a schema with
+// mixed classification thresholds lands in: one pass per distinct threshold,
+// with tasks at the request threshold sharing the first.
+func TestSchemaPasses(t *testing.T) {
+	f := gliner2test.New().AddEntity("person", `Mario Rossi`)
+	s := newTestServer(f)
+	if err := s.swapModel(s.repo, s.variant); err != nil {
+		t.Fatal(err)
+	}
+	const req = `{"task": "schema", "text": "Mario Rossi works at Apple.", "threshold": 0.5, "schema": {
+		"entities": ["person"],
+		"classifications": {
+			"a": ["x", "y"],
+			"b": {"labels": ["x", "y"], "cls_threshold": 0.3},
+			"c": {"labels": ["x", "y"], "cls_threshold": 0.5},
+			"d": {"labels": ["x", "y"], "multi_label": true, "cls_threshold": 0.3},
+			"e": {"labels": ["x", "y"], "cls_threshold": 0.8}
+		},
+		"relations": ["works_at"]
+	}}`
+	if rec, out := do(t, s.routes(), http.MethodPost, "/gliner-2", req); rec.Code != http.StatusOK {
+		t.Fatalf("schema = %d %v", rec.Code, out)
+	}
+
+	type pass struct {
+		threshold float32
+		tasks     []string
+	}
+	want := []pass{
+		{0.5, []string{"entities", "classifications:a", "classifications:c", "relations:works_at"}},
+		{0.3, []string{"classifications:b", "classifications:d"}},
+		{0.8, []string{"classifications:e"}},
+	}
+	calls := f.Calls()
+	if len(calls) != len(want) {
+		t.Fatalf("forward passes = %d, want %d: %+v", len(calls), len(want), calls)
+	}
+	for i, c := range calls {
+		var names []string
+		for _, task := range c.Tasks {
+			name := task.Type
+			if task.Name != "" {
+				name += ":" + task.Name
+			}
+			names = append(names, name)
+		}
+		if c.Threshold != want[i].threshold || !slices.Equal(names, want[i].tasks) {
+			t.Errorf("pass %d = %v at %v, want %v at %v", i, names, c.Threshold, want[i].tasks, want[i].threshold)
+		}
+	}
+}
+
+// TestSchemaSinglePassEngine is TestSchemaSinglePass against the real engine:
+// a schema's combined pass must give the answers of one pass per task. Like
+// the package gliner2 smoke tests it needs the native library and the model
+// weights, and is skipped without them.
+func TestSchemaSinglePassEngine(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping native test in -short mode (downloads model weights)")
+	}
+	if err := gliner2.Init(); err != nil {
+		t.Skipf("native gliner2_binding not available: %v", err)
+	}
+	eng, err := gliner2.NewFromHuggingFace("SemplificaAI/gliner2-multi-v1-onnx", "fp32_v2")
+	if err != nil {
+		t.Skipf("engine load failed (needs model download + onnxruntime): %v", err)
+	}
+	defer eng.Close()
+
+	const req = `{"task": "schema", "text": "Tim Cook is the CEO of Apple, which is based in Cupertino. Its results were excellent.",
+		"include_confidence": true, "schema": {
+		"entities": ["person", "company", "location"],
+		"classifications": {"sentiment": ["positive", "negative"]},
+		"relations": ["works_for", "located_in"]
+	}}`
+	run := func(ext gliner2.Extractor) map[string]any {
+		t.Helper()
+		s := &server{repo: "org/model", variant: "fp32_v2", defaultName: "default",
+			load: func(string, string) (gliner2.Extractor, error) { return ext, nil }}
+		if err := s.swapModel(s.repo, s.variant); err != nil {
+			t.Fatal(err)
+		}
+		rec, out := do(t, s.routes(), http.MethodPost, "/gliner-2", req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("schema = %d %v", rec.Code, out)
+		}
+		res, _ := out["result"].(map[string]any)
+		return res
+	}
+	got := run(eng)
+	want := run(perTask{eng})
+	if !approxEqual(got, want, 0.05) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		t.Errorf("combined pass = %s\nseparate passes = %s", gotJSON, wantJSON)
+	}
+}
+
+// approxEqual compares decoded JSON values, allowing numbers to differ by tol.
+func approxEqual(a, b any, tol float64) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if w, ok := b[k]; !ok || !approxEqual(v, w, tol) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !approxEqual(a[i], b[i], tol) {
+				return false
+			}
+		}
+		return true
+	case float64:
+		b, ok := b.(float64)
+		return ok && math.Abs(a-b) <= tol
+	default:
+		return a == b
+	}
+}
+
+// batchRecorder records the size of every ExtractBatch call made on the Fake.
+type batchRecorder struct {
+	*gliner2test.Fake
+	mu    sync.Mutex
+	sizes []int
+}
+
+func (b *batchRecorder) ExtractBatch(texts []string, tasks []gliner2.Task, threshold float32, flatNER bool) ([]*gliner2.Result, error) {
+	b.mu.Lock()
+	b.sizes = append(b.sizes, len(texts))
+	b.mu.Unlock()
+	return b.Fake.ExtractBatch(texts, tasks, threshold, flatNER)
+}
+
+// gated blocks every Extract until release is closed, signalling started as
+// each one begins.
+type gated struct {
+	*gliner2test.Fake
+	started chan struct{}
+	release chan struct{}
+}
+
+func (g gated) Extract(text string, tasks []gliner2.Task, threshold float32, flatNER bool) (*gliner2.Result, error) {
+	g.started <- struct{}{}
+	<-g.release
+	return g.Fake.Extract(text, tasks, threshold, flatNER)
+}
+
+func TestBackpressure(t *testing.T) {
+	g := gated{Fake: gliner2test.New(), started: make(chan struct{}, 8), release: make(chan struct{})}
+	s := newTestServer(g.Fake)
+	s.load = func(string, string) (gliner2.Extractor, error) { return g, nil }
+	s.queueDepth, s.queueTimeout = 2, time.Minute
+	h := s.routes()
+
+	rec, _ := do(t, h, http.MethodPost, "/gliner-2", entitiesReq)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("while loading = %d (Retry-After %q), want 503 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if err := s.swapModel(s.repo, s.variant); err != nil {
+		t.Fatal(err)
+	}
+
+	// One request runs and one waits: the queue is full.
+	codes := make(chan int, 2)
+	for range 2 {
+		go func() {
+			rec, _ := do(t, h, http.MethodPost, "/gliner-2", entitiesReq)
+			codes <- rec.Code
+		}()
+	}
+	<-g.started
+	for s.model.Load().queue.depth() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	rec, out := do(t, h, http.MethodPost, "/gliner-2", entitiesReq)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" || out["detail"] == nil {
+		t.Errorf("queue full = %d %v (Retry-After %q), want 429 with Retry-After: 1 and a detail", rec.Code, out, rec.Header().Get("Retry-After"))
+	}
+	close(g.release)
+	for range 2 {
+		if code := <-codes; code != http.StatusOK {
+			t.Errorf("queued request = %d, want 200", code)
+		}
+	}
+}
+
+// panicky panics on its first Extract call.
+type panicky struct {
+	*gliner2test.Fake
+	once *sync.Once
+}
+
+func (p panicky) Extract(text string, tasks []gliner2.Task, threshold float32, flatNER bool) (*gliner2.Result, error) {
+	p.once.Do(func() { panic("engine panic") })
+	return p.Fake.Extract(text, tasks, threshold, flatNER)
+}
+
+func TestExtractPanicReleasesLock(t *testing.T) {
+	p := panicky{Fake: gliner2test.New(), once: new(sync.Once)}
+	m := &model{eng: p, repo: "org/model"}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("first extract did not panic")
+			}
+		}()
+		m.extract(context.Background(), "text", []gliner2.Task{gliner2.Entities("person")}, 0.5, false)
+	}()
+	done := make(chan error)
+	go func() {
+		_, err := m.extract(context.Background(), "text", []gliner2.Task{gliner2.Entities("person")}, 0.5, false)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("extract after a panic: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("extract after a panic is stuck on the engine lock")
+	}
+}
+
+func TestRequestQueueTimeout(t *testing.T) {
+	q := newRequestQueue(2, 1, 10*time.Millisecond)
+	ctx := context.Background()
+	leave, err := q.enter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.enter(ctx); !errors.Is(err, errQueueTimeout) {
+		t.Errorf("second enter = %v, want errQueueTimeout", err)
+	}
+	if q.depth() != 1 {
+		t.Errorf("depth after a timeout = %d, want 1", q.depth())
+	}
+	leave()
+	if leave, err = q.enter(ctx); err != nil {
+		t.Errorf("enter after leave: %v", err)
+	} else {
+		leave()
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	g := gated{Fake: gliner2test.New().AddEntity("person", `Mario Rossi`), started: make(chan struct{}, 1), release: make(chan struct{})}
+	s := newTestServer(g.Fake)
+	s.load = func(string, string) (gliner2.Extractor, error) { return g, nil }
+	if err := s.swapModel(s.repo, s.variant); err != nil {
+		t.Fatal(err)
+	}
+	h := s.routes()
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	codes := make(chan int, 1)
+	go func() {
+		resp, err := http.Post(ts.URL+"/gliner-2", "application/json", strings.NewReader(entitiesReq))
+		if err != nil {
+			t.Error(err)
+			codes <- 0
+			return
+		}
+		resp.Body.Close()
+		codes <- resp.StatusCode
+	}()
+	<-g.started
+
+	done := make(chan error, 1)
+	go func() { done <- s.shutdown(context.Background(), ts.Config) }()
+	for !s.stopping.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	if rec, out := do(t, h, http.MethodGet, "/health/ready", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("ready while draining = %d %v, want 503", rec.Code, out)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("shutdown returned before the in-flight request finished: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if g.Fake.Closed() {
+		t.Fatal("engine closed while a request was in flight")
+	}
+
+	close(g.release)
+	if code := <-codes; code != http.StatusOK {
+		t.Errorf("in-flight request = %d, want 200", code)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+	if !g.Fake.Closed() {
+		t.Error("engine not closed after shutdown")
+	}
+	if err := s.swapModel(s.repo, s.variant); !errors.Is(err, errShuttingDown) {
+		t.Errorf("swap after shutdown = %v, want errShuttingDown", err)
+	}
+}
+
+// TestShutdownDeadline verifies shutdown gives up on a request that outlives
+// its context and leaves that request's engine open.
+func TestShutdownDeadline(t *testing.T) {
+	g := gated{Fake: gliner2test.New().AddEntity("person", `Mario Rossi`), started: make(chan struct{}, 1), release: make(chan struct{})}
+	s := newTestServer(g.Fake)
+	s.load = func(string, string) (gliner2.Extractor, error) { return g, nil }
+	if err := s.swapModel(s.repo, s.variant); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.routes())
+	defer ts.Close()
+
+	codes := make(chan int, 1)
+	go func() {
+		resp, err := http.Post(ts.URL+"/gliner-2", "application/json", strings.NewReader(entitiesReq))
+		if err != nil {
+			codes <- 0
+			return
+		}
+		resp.Body.Close()
+		codes <- resp.StatusCode
+	}()
+	<-g.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.shutdown(ctx, ts.Config); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("shutdown = %v, want context.DeadlineExceeded", err)
+	}
+	if g.Fake.Closed() {
+		t.Error("engine closed while a request was still using it")
+	}
+	close(g.release)
+	<-codes
+}
+
+func TestMetrics(t *testing.T) {
+	f := gliner2test.New().AddEntity("person", `Mario Rossi`).AddEntity("company", `Apple`)
+	s := newTestServer(f)
+	s.reg = gliner2.NewRegistryWithLoader(map[string]gliner2.ModelConfig{"legal": {Repo: "org/metrics-legal"}}, 0, timeLoads(gliner2test.New().Loader()))
+	s.named = map[string]*model{"legal": {reg: s.reg, name: "legal", repo: "org/metrics-legal"}}
+	if err := s.swapModel(s.repo, s.variant); err != nil {
+		t.Fatal(err)
+	}
+	h := s.routes()
+
+	ok := requestsTotal.WithLabelValues("extract_entities", "200")
+	bad := requestsTotal.WithLabelValues("other", "422")
+	before := map[string]float64{
+		"ok": testutil.ToFloat64(ok), "bad": testutil.ToFloat64(bad),
+		"texts": testutil.ToFloat64(textsTotal), "chars": testutil.ToFloat64(charactersTotal),
+		"person": testutil.ToFloat64(entitiesTotal.WithLabelValues("person")),
+	}
+	do(t, h, http.MethodPost, "/gliner-2", entitiesReq)
+	do(t, h, http.MethodPost, "/gliner-2", `{"task": "nope", "text": "x"}`)
+
+	delta := func(name string, c prometheus.Collector) float64 { return testutil.ToFloat64(c) - before[name] }
+	if d := delta("ok", ok); d != 1 {
+		t.Errorf("extract_entities/200 requests += %v, want 1", d)
+	}
+	if d := delta("bad", bad); d != 1 {
+		t.Errorf("other/422 requests += %v, want 1", d)
+	}
+	if d := delta("texts", textsTotal); d != 1 {
+		t.Errorf("texts += %v, want 1", d)
+	}
+	if d := delta("chars", charactersTotal); d != float64(len("Mario Rossi works at Apple.")) {
+		t.Errorf("characters += %v", d)
+	}
+	if d := delta("person", entitiesTotal.WithLabelValues("person")); d != 1 {
+		t.Errorf("person entities += %v, want 1", d)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	for _, name := range []string{"gliner2_requests_total", "gliner2_inference_seconds_bucket", "gliner2_queue_rejected_total", "go_goroutines"} {
+		if !strings.Contains(rec.Body.String(), name) {
+			t.Errorf("/metrics lacks %s", name)
+		}
+	}
+
+	// Load and lock-wait series are per model, named models included.
+	if rec, out := do(t, h, http.MethodPost, "/gliner-2/legal", entitiesReq); rec.Code != http.StatusOK {
+		t.Fatalf("named model = %d %v", rec.Code, out)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	for _, series := range []string{
+		`gliner2_model_load_seconds_count{model="org/model:fp32_v2"}`,
+		`gliner2_model_load_seconds_count{model="org/metrics-legal"}`,
+		`gliner2_engine_lock_wait_seconds_count{model="org/model:fp32_v2"}`,
+		`gliner2_engine_lock_wait_seconds_count{model="org/metrics-legal"}`,
+	} {
+		if !strings.Contains(rec.Body.String(), series) {
+			t.Errorf("/metrics lacks %s", series)
+		}
+	}
+}
+
+// The global tracer delegates to the first provider installed, so the span
+// recorder is installed once per test binary.
+var (
+	spanRecorderOnce sync.Once
+	spanRecorder     *tracetest.SpanRecorder
+)
+
+func TestTracing(t *testing.T) {
+	spanRecorderOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	})
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	s := newTestServer(gliner2test.New().AddEntity("person", `Mario Rossi`))
+	s.queueDepth = 4
+	if err := s.swapModel(s.repo, s.variant); err != nil {
+		t.Fatal(err)
+	}
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	start := time.Now()
+	req := httptest.NewRequest(http.MethodPost, "/gliner-2", strings.NewReader(entitiesReq))
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("extract = %d %s", rec.Code, rec.Body)
+	}
+	// A model path is named by its route, not the model it names.
+	req = httptest.NewRequest(http.MethodPost, "/gliner-2/nope", strings.NewReader(entitiesReq))
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	s.routes().ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, sp := range spanRecorder.Ended() {
+		if sp.SpanContext().TraceID().String() == traceID && sp.EndTime().After(start) {
+			spans[sp.Name()] = sp
+		}
+	}
+	for _, name := range []string{"POST /gliner-2", "POST /gliner-2/{model}", "request.decode", "queue.wait", "gliner2.Extract", "response.encode"} {
+		if spans[name] == nil {
+			t.Errorf("no %q span; got %v", name, slices.Collect(maps.Keys(spans)))
+		}
+	}
+	if sp := spans["POST /gliner-2/{model}"]; sp != nil {
+		attrs := map[attribute.Key]string{}
+		for _, kv := range sp.Attributes() {
+			attrs[kv.Key] = kv.Value.Emit()
+		}
+		if attrs["url.path"] != "/gliner-2/nope" || attrs["http.route"] != "/gliner-2/{model}" {
+			t.Errorf("model route span attributes = %v", attrs)
+		}
+	}
+	if sp := spans["gliner2.Extract"]; sp != nil {
+		attrs := map[attribute.Key]attribute.Value{}
+		for _, kv := range sp.Attributes() {
+			attrs[kv.Key] = kv.Value
+		}
+		if attrs["gliner2.tasks"].AsInt64() != 1 || attrs["gliner2.text_length"].AsInt64() != int64(len("Mario Rossi works at Apple.")) {
+			t.Errorf("gliner2.Extract attributes = %v", sp.Attributes())
+		}
+	}
+}
+
+func TestRequestLimits(t *testing.T) {
+	f := gliner2test.New().AddEntity("person", `Mario Rossi`)
+	s := newTestServer(f)
+	s.limits = limits{bodyBytes: 512, texts: 2, textChars: 40, labels: 3, tasks: 3}
+	if err := s.swapModel(s.repo, s.variant); err != nil {
+		t.Fatal(err)
+	}
+	h := s.routes()
+
+	tests := []struct {
+		name, body string
+		code       int
+		detail     string // substring of the detail, if set
+	}{
+		{"within limits", `{"task": "extract_entities", "text": ["Mario Rossi", "Anna"], "schema": ["person", "company", "place"]}`, http.StatusOK, ""},
+		{"body too large", `{"task": "extract_entities", "text": "` + strings.Repeat("x", 600) + `", "schema": ["person"]}`, http.StatusRequestEntityTooLarge, ""},
+		{"too many texts", `{"task": "extract_entities", "text": ["a", "b", "c"], "schema": ["person"]}`, http.StatusRequestEntityTooLarge, ""},
+		{"text too long", `{"task": "extract_entities", "text": "` + strings.Repeat("é", 41) + `", "schema": ["person"]}`, http.StatusRequestEntityTooLarge, ""},
+		{"too many labels", `{"task": "extract_entities", "text": "x", "schema": ["a", "b", "c", "d"]}`, http.StatusUnprocessableEntity, ""},
+		{"too many schema tasks", `{"task": "schema", "text": "x", "schema": {"entities": ["person"], "relations": ["a", "b", "c"]}}`, http.StatusUnprocessableEntity, "tasks"},
+		{"too many relation types", `{"task": "schema", "text": "x", "schema": {"relations": ["a", "b", "c", "d"]}}`, http.StatusUnprocessableEntity, "relation types"},
+		{"schema within limits", `{"task": "schema", "text": "x", "schema": {"entities": ["person"], "relations": ["a", "b"]}}`, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := len(f.Calls())
+			rec, out := do(t, h, http.MethodPost, "/gliner-2", tt.body)
+			if rec.Code != tt.code {
+				t.Fatalf("code = %d %v, want %d", rec.Code, out, tt.code)
+			}
+			if tt.code == http.StatusOK {
+				return
+			}
+			if d, _ := out["detail"].(string); d == "" || !strings.Contains(d, tt.detail) {
+				t.Errorf("body = %v, want a detail message naming %q", out, tt.detail)
+			}
+			if n := len(f.Calls()) - calls; n != 0 {
+				t.Errorf("rejected request ran %d engine calls", n)
+			}
+		})
+	}
+}
+
+func TestV1Extract(t *testing.T) {
+	text := "Mario Rossi works at Apple."
+	mario := gliner2.Entity{Text: "Mario Rossi", Label: "person", Score: 0.9, StartTok: 0, EndTok: 2, StartChar: 0, EndChar: 11}
+	apple := gliner2.Entity{Text: "Apple", Label: "company", Score: 0.8, StartTok: 4, EndTok: 5, StartChar: 21, EndChar: 26}
+	f := gliner2test.New().SetResult(text, &gliner2.Result{
+		Entities:  []gliner2.Entity{mario, apple},
+		Relations: []gliner2.Relation{{Head: mario, Tail: apple, RelationType: "works_at"}},
+	})
+	rec := &batchRecorder{Fake: f}
+	s := newTestServer(f)
+	s.load = func(string, string) (gliner2.Extractor, error) { return rec, nil }
+	if err := s.swapModel(s.repo, s.variant); err != nil {
+		t.Fatal(err)
+	}
+	h := s.routes()
+
+	body := `{"tasks": [{"type": "entities", "labels": ["person", "company"]}, {"type": "relations", "name": "works_at", "fields": ["head", "tail"]}],
+		"documents": [{"id": "doc-a", "text": "Mario Rossi works at Apple."}, {"text": "Nothing here."}], "threshold": 0.4}`
+	resp, _ := do(t, h, http.MethodPost, "/v1/extract", body)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("v1 extract = %d %s", resp.Code, resp.Body)
+	}
+	var out v1Response
+	if err := json.Unmarshal(resp.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Model != "org/model:fp32_v2" || len(out.Results) != 2 || out.Results[0].ID != "doc-a" || out.Results[1].ID != "1" {
+		t.Fatalf("response = %+v", out)
+	}
+	got := out.Results[0].Result
+	if len(got.Entities) != 2 || got.Entities[1] != apple {
+		t.Errorf("entities = %+v, want full entities with token offsets", got.Entities)
+	}
+	if len(got.Relations) != 1 || got.Relations[0].Head != mario || got.Relations[0].Tail != apple {
+		t.Errorf("relations = %+v, want head/tail entities", got.Relations)
+	}
+	if !strings.Contains(resp.Body.String(), `"entities":[]`) {
+		t.Errorf("empty result should have empty lists: %s", resp.Body)
+	}
+	if fmt.Sprint(rec.sizes) != "[2]" {
+		t.Errorf("engine batches = %v, want one batch of 2", rec.sizes)
+	}
+	if calls := f.Calls(); len(calls) != 2 || calls[0].Threshold != 0.4 || len(calls[0].Tasks) != 2 {
+		t.Errorf("calls = %+v", calls)
+	}
+
+	for _, bad := range []string{
+		`{"documents": [{"text": "x"}]}`,
+		`{"tasks": [{"type": "entities", "labels": ["person"]}], "documents": []}`,
+		`{"tasks": [{"type": "nope"}], "documents": [{"text": "x"}]}`,
+		`{"tasks": [{"type": "entities", "labels": ["person"]}], "documents": [{"id": "1", "text": "x"}, {"text": "y"}]}`,
+		`{"tasks": [{"type": "entities", "labels": ["person"]}], "documents": [{"text": "x"}], "threshold": 2}`,
+	} {
+		if resp, out := do(t, h, http.MethodPost, "/v1/extract", bad); resp.Code != http.StatusUnprocessableEntity || out["detail"] == nil {
+			t.Errorf("%s = %d %v, want 422 with a detail", bad, resp.Code, out)
+		}
+	}
+	if resp, _ := do(t, h, http.MethodPost, "/v1/extract/nope", body); resp.Code != http.StatusNotFound {
+		t.Errorf("unknown model = %d, want 404", resp.Code)
+	}
+
+	// Tasks the engine rejects are the client's fault; other failures are not.
+	f.FailOn("unsupported", fmt.Errorf("%w: %q tasks", gliner2.ErrUnsupported, gliner2.CapStructure))
+	f.FailOn("broken", errors.New("onnxruntime: session failed"))
+	for text, want := range map[string]int{"unsupported": http.StatusUnprocessableEntity, "broken": http.StatusInternalServerError} {
+		body := `{"tasks": [{"type": "entities", "labels": ["person"]}], "documents": [{"text": "` + text + `"}]}`
+		if resp, out := do(t, h, http.MethodPost, "/v1/extract", body); resp.Code != want || out["detail"] == nil {
+			t.Errorf("%s = %d %v, want %d with a detail", text, resp.Code, out, want)
+		}
+	}
+}
+
+// TestNonNilResult verifies nonNilResult fills in empty lists on a copy,
+// leaving a possibly shared result untouched.
+func TestNonNilResult(t *testing.T) {
+	shared := &gliner2.Result{Entities: []gliner2.Entity{{Text: "Mario Rossi", Label: "person"}}}
+	out := nonNilResult(shared)
+	if out == shared {
+		t.Fatal("nonNilResult returned its argument")
+	}
+	if shared.Relations != nil || shared.Classifications != nil || shared.Structures != nil {
+		t.Errorf("argument modified: %+v", shared)
+	}
+	if out.Relations == nil || out.Classifications == nil || out.Structures == nil || len(out.Entities) != 1 {
+		t.Errorf("nonNilResult = %+v, want the entity and empty lists", out)
+	}
+	if out := nonNilResult(nil); out.Entities == nil || out.Structures == nil {
+		t.Errorf("nonNilResult(nil) = %+v, want empty lists", out)
+	}
+}
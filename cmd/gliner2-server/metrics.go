@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2"
+)
+
+// Prometheus metrics, served at /metrics from their own registry.
+var (
+	promRegistry = prometheus.NewRegistry()
+
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gliner2_requests_total",
+		Help: "Extraction requests by task and HTTP status code.",
+	}, []string{"task", "code"})
+	queueRejectedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gliner2_queue_rejected_total",
+		Help: "Requests answered 429 because the request queue was full or timed out.",
+	})
+	queueWaitSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "gliner2_queue_wait_seconds",
+		Help:    "Time requests spent in the model's request queue before running.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 9),
+	})
+	inferenceSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "gliner2_inference_seconds",
+		Help:    "Duration of engine Extract and ExtractBatch calls.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	})
+	engineLockWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gliner2_engine_lock_wait_seconds",
+		Help:    "Time spent waiting for exclusive use of a model's engine, by model.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+	}, []string{"model"})
+	modelLoadSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gliner2_model_load_seconds",
+		Help:    "Time to load and warm up a model, by model.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	}, []string{"model"})
+	textsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gliner2_texts_total",
+		Help: "Texts processed.",
+	})
+	charactersTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gliner2_characters_total",
+		Help: "Characters (Unicode code points) of the texts processed.",
+	})
+	entitiesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gliner2_entities_total",
+		Help: "Entities extracted, by label. Labels past the first " + strconv.Itoa(maxEntityLabels) + " are counted as \"other\".",
+	}, []string{"label"})
+)
+
+// maxEntityLabels caps the label values of gliner2_entities_total: labels come
+// from request schemas, so a client could otherwise grow the series without
+// bound.
+const maxEntityLabels = 256
+
+var (
+	entityLabelsMu sync.Mutex
+	entityLabels   = map[string]bool{}
+)
+
+// knownTasks are the task label values of gliner2_requests_total; anything
+// else is "other".
+var knownTasks = map[string]bool{"extract_entities": true, "classify_text": true, "extract_relations": true, "schema": true, "extract_json": true, "v1_extract": true}
+
+func init() {
+	promRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestsTotal, queueRejectedTotal, queueWaitSeconds, inferenceSeconds, engineLockWaitSeconds, modelLoadSeconds,
+		textsTotal, charactersTotal, entitiesTotal,
+	)
+}
+
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(promRegistry, promhttp.HandlerOpts{Registry: promRegistry})
+}
+
+// observeRequest counts a finished extraction request.
+func observeRequest(task string, code int) {
+	if !knownTasks[task] {
+		task = "other"
+	}
+	requestsTotal.WithLabelValues(task, strconv.Itoa(code)).Inc()
+}
+
+func observeText(text string) {
+	textsTotal.Inc()
+	charactersTotal.Add(float64(utf8.RuneCountInString(text)))
+}
+
+func observeEntities(results ...*gliner2.Result) {
+	for _, res := range results {
+		if res == nil {
+			continue
+		}
+		for _, e := range res.Entities {
+			entitiesTotal.WithLabelValues(entityLabel(e.Label)).Inc()
+		}
+	}
+}
+
+// timeLoads wraps a Registry load function to record each successful load in
+// gliner2_model_load_seconds.
+func timeLoads(load func(gliner2.ModelConfig) (gliner2.Extractor, error)) func(gliner2.ModelConfig) (gliner2.Extractor, error) {
+	return func(cfg gliner2.ModelConfig) (gliner2.Extractor, error) {
+		start := time.Now()
+		eng, err := load(cfg)
+		if err == nil {
+			modelLoadSeconds.WithLabelValues(modelID(cfg.Repo, cfg.Variant)).Observe(since(start))
+		}
+		return eng, err
+	}
+}
+
+func entityLabel(label string) string {
+	entityLabelsMu.Lock()
+	defer entityLabelsMu.Unlock()
+	if !entityLabels[label] {
+		if len(entityLabels) >= maxEntityLabels {
+			return "other"
+		}
+		entityLabels[label] = true
+	}
+	return label
+}
+
+// statusWriter records the status code written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
+
+func since(start time.Time) float64 { return time.Since(start).Seconds() }
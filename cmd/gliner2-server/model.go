@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// model is one loaded engine plus the identity reported to clients. Requests
+// hold inflight for reading while they use the engine; retiring a model takes
+// it for writing, which waits for those requests to drain before Close.
+//
+// Named models from -models are also wrapped in a model, with reg set: their
+// engines live in the Registry, which loads, serializes and evicts them, so
+// they are never retired here.
+type model struct {
+	eng      gliner2.Extractor
+	reg      *gliner2.Registry
+	name     string // registry model name; empty for the default model
+	repo     string
+	variant  string
+	loadedAt time.Time
+
+	mu       sync.Mutex    // Extractors are not safe for concurrent Extract calls
+	queue    *requestQueue // bounds waiting requests; nil when unbounded
+	limits   limits        // checked before each engine call
+	inflight sync.RWMutex
+	retired  bool // guarded by inflight
+}
+
+// id is the model identity sent in the X-GLiNER2-Model header and used as the
+// model label of its metrics.
+func (m *model) id() string { return modelID(m.repo, m.variant) }
+
+func modelID(repo, variant string) string {
+	if variant == "" {
+		return repo
+	}
+	return repo + ":" + variant
+}
+
+// extract runs tasks over text on the model's engine, in a span carrying the
+// task count and text length.
+func (m *model) extract(ctx context.Context, text string, tasks []gliner2.Task, threshold float32, flatNER bool) (*gliner2.Result, error) {
+	ctx, span := tracer.Start(ctx, "gliner2.Extract", trace.WithAttributes(
+		attribute.Int("gliner2.tasks", len(tasks)),
+		attribute.Int("gliner2.text_length", len(text))))
+	eng, release, err := m.engine(ctx)
+	if err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+	defer release()
+	var res *gliner2.Result
+	m.locked(func() { res, err = eng.Extract(text, tasks, threshold, flatNER) })
+	observeEntities(res)
+	endSpan(span, err)
+	return res, err
+}
+
+func (m *model) extractBatch(ctx context.Context, texts []string, tasks []gliner2.Task, threshold float32, flatNER bool) ([]*gliner2.Result, error) {
+	eng, release, err := m.engine(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var res []*gliner2.Result
+	m.locked(func() { res, err = eng.ExtractBatch(texts, tasks, threshold, flatNER) })
+	observeEntities(res...)
+	return res, err
+}
+
+// engine returns the extractor to run a call on and a func to release it:
+// the default model's engine, or a named model's from the registry, loaded
+// first if needed. The load is not part of the call's inference time.
+func (m *model) engine(ctx context.Context) (gliner2.Extractor, func(), error) {
+	if m.reg == nil {
+		return m.eng, func() {}, nil
+	}
+	return m.reg.Acquire(ctx, m.name)
+}
+
+// locked runs call under the engine lock, recording how long it waited for
+// the lock and how long call took. The lock is released even if call panics.
+func (m *model) locked(call func()) {
+	start := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	engineLockWaitSeconds.WithLabelValues(m.id()).Observe(since(start))
+	start = time.Now()
+	defer func() { inferenceSeconds.Observe(since(start)) }()
+	call()
+}
+
+// newModel wraps an engine (or, with reg set, a registry model) and attaches
+// a request queue when -queue-depth is set. One request runs at a time.
+func (s *server) newModel(m *model) *model {
+	m.limits = s.limits
+	if s.queueDepth > 0 {
+		m.queue = newRequestQueue(s.queueDepth, 1, s.queueTimeout)
+	}
+	return m
+}
+
+// acquireModel returns the model named name ("" for the default), held until
+// releaseModel, or nil if the default model is not loaded yet. A default model
+// that was swapped out between the load and the read lock is skipped in favor
+// of its replacement. ok is false for an unknown name.
+func (s *server) acquireModel(name string) (m *model, ok bool) {
+	if name != "" && name != s.defaultName {
+		m, ok := s.named[name]
+		if ok {
+			m.inflight.RLock()
+		}
+		return m, ok
+	}
+	for {
+		m := s.model.Load()
+		if m == nil {
+			return nil, true
+		}
+		m.inflight.RLock()
+		if !m.retired {
+			return m, true
+		}
+		m.inflight.RUnlock()
+	}
+}
+
+func (s *server) releaseModel(m *model) {
+	m.inflight.RUnlock()
+}
+
+// acquireOrReply is acquireModel for a handler: it names the model in the
+// X-GLiNER2-Model header, or replies 404 for an unknown name or 503 while the
+// model loads and returns nil. A named model's load is started by the first
+// request for it, which waits; requests arriving during the load get the 503
+// rather than waiting with it.
+func (s *server) acquireOrReply(w http.ResponseWriter, name string) *model {
+	m, ok := s.acquireModel(name)
+	if !ok {
+		writeDetail(w, http.StatusNotFound, fmt.Sprintf("unknown model %q", name))
+		return nil
+	}
+	if m != nil && m.reg != nil && m.reg.Loading(m.name) {
+		s.releaseModel(m)
+		m = nil
+	}
+	if m == nil {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterLoading))
+		writeDetail(w, http.StatusServiceUnavailable, "model is loading")
+		return nil
+	}
+	w.Header().Set("X-GLiNER2-Model", m.id())
+	return m
+}
+
+// enterQueue waits for the model's request queue (see requestQueue), in a
+// span. leave must be called once the request is done.
+func (m *model) enterQueue(ctx context.Context) (leave func(), err error) {
+	if m.queue == nil {
+		return func() {}, nil
+	}
+	_, span := tracer.Start(ctx, "queue.wait")
+	start := time.Now()
+	leave, err = m.queue.enter(ctx)
+	queueWaitSeconds.Observe(since(start))
+	endSpan(span, err)
+	return leave, err
+}
+
+// startReload loads repo/variant in the background and swaps it in once it is
+// warm. It reports false, doing nothing, if a load is already in progress.
+func (s *server) startReload(repo, variant string) bool {
+	s.reloadMu.Lock()
+	if s.reloading {
+		s.reloadMu.Unlock()
+		return false
+	}
+	s.reloading = true
+	s.reloadMu.Unlock()
+
+	go func() {
+		err := s.swapModel(repo, variant)
+		s.reloadMu.Lock()
+		s.reloading = false
+		s.reloadErr = ""
+		if err != nil {
+			s.reloadErr = err.Error()
+		}
+		s.reloadMu.Unlock()
+		if err == nil || errors.Is(err, errShuttingDown) {
+			return
+		}
+		cur := s.model.Load()
+		if cur == nil {
+			log.Fatalf("load model: %v", err)
+		}
+		log.Printf("reload %q (variant %q) failed, still serving %s: %v", repo, variant, cur.id(), err)
+	}()
+	return true
+}
+
+// swapModel loads and warms a new engine, makes it current, then drains and
+// closes the one it replaces. The old model keeps serving until the swap.
+func (s *server) swapModel(repo, variant string) error {
+	start := time.Now()
+	eng, err := s.load(repo, variant)
+	if err != nil {
+		return err
+	}
+	modelLoadSeconds.WithLabelValues(modelID(repo, variant)).Observe(since(start))
+	m := s.newModel(&model{eng: eng, repo: repo, variant: variant, loadedAt: time.Now()})
+	s.swapMu.Lock()
+	if s.stopping.Load() {
+		s.swapMu.Unlock()
+		eng.Close()
+		return errShuttingDown
+	}
+	old := s.model.Swap(m)
+	s.ready.Store(true)
+	s.swapMu.Unlock()
+	log.Printf("serving model %s", m.id())
+	if old != nil {
+		s.retireReplaced(old)
+	}
+	return nil
+}
+
+// retireReplaced retires a model a reload swapped out. Requests still using it
+// after drainTimeout (0: no limit) do not keep it open: its engine is closed
+// anyway and their later calls on it fail.
+func (s *server) retireReplaced(m *model) {
+	ctx := context.Background()
+	if s.drainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.drainTimeout)
+		defer cancel()
+	}
+	if m.retire(ctx) != nil {
+		log.Printf("model %s still busy after %s; closing it anyway", m.id(), s.drainTimeout)
+		m.eng.Close()
+	}
+}
+
+var errShuttingDown = errors.New("server is shutting down")
+
+// retire waits for the model's in-flight requests to drain, then closes its
+// engine. If ctx is done first, it returns ctx's error and leaves the engine
+// open to the requests still using it; the caller decides what to do next.
+func (m *model) retire(ctx context.Context) error {
+	start := time.Now()
+	drained := make(chan struct{})
+	go func() {
+		m.inflight.Lock()
+		m.retired = true
+		m.inflight.Unlock()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	m.eng.Close()
+	log.Printf("closed model %s after draining for %s", m.id(), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// closeModels retires the current model and closes the registry, waiting for
+// requests still using them until ctx is done; engines still busy then are
+// left open. It is called once stopping is set, so no new model can be
+// swapped in behind it.
+func (s *server) closeModels(ctx context.Context) error {
+	s.swapMu.Lock()
+	m := s.model.Swap(nil)
+	s.swapMu.Unlock()
+	if m != nil {
+		if err := m.retire(ctx); err != nil {
+			log.Printf("model %s still busy; not closing it", m.id())
+			return err
+		}
+	}
+	if s.reg == nil {
+		return nil
+	}
+	closed := make(chan struct{})
+	go func() {
+		s.reg.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		log.Printf("named models still busy; not waiting to close them")
+		return ctx.Err()
+	}
+}
+
+// reloadRequest is the optional body of POST /admin/reload. Omitted fields keep
+// the current model's value; an explicit empty variant selects the repo root.
+type reloadRequest struct {
+	Repo    *string `json:"repo"`
+	Variant *string `json:"variant"`
+}
+
+// handleReload starts a background reload, of the current repo/variant (e.g.
+// to pick up a new revision) or of the ones named in the body. It replies 202
+// immediately; progress and failures are reported by /health. It is disabled
+// unless -admin-key is set, as each reload loads and warms up a whole model.
+func (s *server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeDetail(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.adminKey == "" {
+		writeDetail(w, http.StatusForbidden, "reload is disabled; start the server with -admin-key to enable it")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-API-Key")), []byte(s.adminKey)) != 1 {
+		writeDetail(w, http.StatusUnauthorized, "Invalid or expired API key")
+		return
+	}
+	var req reloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeDetail(w, http.StatusUnprocessableEntity, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	repo, variant := s.currentIdentity()
+	if req.Repo != nil {
+		repo = *req.Repo
+	}
+	if req.Variant != nil {
+		variant = *req.Variant
+	}
+	if repo == "" {
+		writeDetail(w, http.StatusUnprocessableEntity, "repo is required")
+		return
+	}
+	if !s.startReload(repo, variant) {
+		writeDetail(w, http.StatusConflict, "a model load is already in progress")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{"status": "reloading", "repo": repo, "variant": variant})
+}
+
+// currentIdentity returns the repo/variant being served, or the startup flags
+// while the first model is still loading.
+func (s *server) currentIdentity() (repo, variant string) {
+	if m := s.model.Load(); m != nil {
+		return m.repo, m.variant
+	}
+	return s.repo, s.variant
+}
+
+// modelStatus is the model section of /health.
+func (s *server) modelStatus() map[string]any {
+	s.reloadMu.Lock()
+	st := map[string]any{"reloading": s.reloading}
+	if s.reloadErr != "" {
+		st["last_reload_error"] = s.reloadErr
+	}
+	s.reloadMu.Unlock()
+	if m := s.model.Load(); m != nil {
+		st["id"] = m.id()
+		st["repo"] = m.repo
+		st["variant"] = m.variant
+		st["loaded_at"] = m.loadedAt.UTC().Format(time.RFC3339)
+		if m.queue != nil {
+			st["queued"] = m.queue.depth()
+		}
+	}
+	return st
+}
+
+// reloadOnSIGHUP reloads the current repo/variant whenever the process gets
+// SIGHUP.
+func (s *server) reloadOnSIGHUP() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			repo, variant := s.currentIdentity()
+			log.Printf("SIGHUP: reloading %q (variant %q)", repo, variant)
+			if !s.startReload(repo, variant) {
+				log.Printf("SIGHUP: a model load is already in progress")
+			}
+		}
+	}()
+}
+
+// loadModels reads a -models file: a JSON object mapping model names to
+// gliner2.ModelConfig, e.g.
+//
+//	{"legal": {"repo": "org/gliner2-legal-onnx", "variant": "fp32_v2", "memory_bytes": 1500000000}}
+//
+// model_type defaults to "huggingface" and warmup to the server's -warmup.
+func loadModels(path string, warmup bool) (map[string]gliner2.ModelConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	out := make(map[string]gliner2.ModelConfig, len(raw))
+	for name, r := range raw {
+		cfg := gliner2.ModelConfig{ModelType: gliner2.ModelTypeHuggingFace, Warmup: warmup}
+		if err := json.Unmarshal(r, &cfg); err != nil {
+			return nil, fmt.Errorf("%s: model %q: %w", path, name, err)
+		}
+		if name == "" || cfg.Repo == "" {
+			return nil, fmt.Errorf("%s: model %q: name and repo are required", path, name)
+		}
+		out[name] = cfg
+	}
+	return out, nil
+}
+
+// namedStatus is the models section of /health; nil without -models.
+func (s *server) namedStatus() []gliner2.ModelStatus {
+	if s.reg == nil {
+		return nil
+	}
+	return s.reg.Models()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Seconds sent in Retry-After when a request is turned away.
+const (
+	retryAfterBusy    = 1
+	retryAfterLoading = 5
+)
+
+var (
+	errQueueFull    = errors.New("server is busy: request queue is full")
+	errQueueTimeout = errors.New("server is busy: timed out waiting in the request queue")
+)
+
+// requestQueue bounds the requests a model holds. At most depth requests are
+// admitted at once (running or waiting); of those, workers run while the rest
+// wait, each for at most timeout. A request beyond depth is refused at once,
+// so a burst costs a 429 instead of a goroutine parked on the engine lock.
+type requestQueue struct {
+	admitted chan struct{}
+	running  chan struct{}
+	timeout  time.Duration // 0: wait as long as the client does
+}
+
+func newRequestQueue(depth, workers int, timeout time.Duration) *requestQueue {
+	workers = min(max(workers, 1), depth)
+	return &requestQueue{
+		admitted: make(chan struct{}, depth),
+		running:  make(chan struct{}, workers),
+		timeout:  timeout,
+	}
+}
+
+// enter admits a request and waits for a worker slot. On success the caller
+// must call leave when done. It fails with errQueueFull, errQueueTimeout or
+// ctx's error.
+func (q *requestQueue) enter(ctx context.Context) (leave func(), err error) {
+	select {
+	case q.admitted <- struct{}{}:
+	default:
+		return nil, errQueueFull
+	}
+	var expired <-chan time.Time
+	if q.timeout > 0 {
+		t := time.NewTimer(q.timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	select {
+	case q.running <- struct{}{}:
+		return func() { <-q.running; <-q.admitted }, nil
+	case <-expired:
+		err = errQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	<-q.admitted
+	return nil, err
+}
+
+// depth reports the number of admitted requests.
+func (q *requestQueue) depth() int { return len(q.admitted) }
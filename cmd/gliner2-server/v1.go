@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// v1Request is the body of POST /v1/extract: the library's task schema run
+// over a batch of documents.
+type v1Request struct {
+	Model     string         `json:"model,omitempty"`
+	Tasks     []gliner2.Task `json:"tasks"`
+	Documents []v1Document   `json:"documents"`
+	Threshold *float32       `json:"threshold,omitempty"` // default 0.5
+	FlatNER   bool           `json:"flat_ner,omitempty"`
+}
+
+// v1Document is one input text. ID is echoed in its result; it defaults to
+// the document's index and must be unique within a request.
+type v1Document struct {
+	ID   string `json:"id,omitempty"`
+	Text string `json:"text"`
+}
+
+// v1Response is the reply of POST /v1/extract: one result per document, in
+// request order.
+type v1Response struct {
+	Model   string     `json:"model"`
+	Results []v1Result `json:"results"`
+}
+
+type v1Result struct {
+	ID     string          `json:"id"`
+	Result *gliner2.Result `json:"result"`
+}
+
+// v1TaskTypes are the Task.Type values /v1/extract accepts.
+var v1TaskTypes = map[string]bool{"entities": true, "relations": true, "classifications": true, "structure": true}
+
+// handleV1Extract serves POST /v1/extract[/{model}]: unlike /gliner-2 it takes
+// []gliner2.Task directly and returns each document's full gliner2.Result,
+// with char and token offsets, scores, relation head/tail entities and
+// structures.
+func (s *server) handleV1Extract(rw http.ResponseWriter, r *http.Request) {
+	w := &statusWriter{ResponseWriter: rw}
+	ctx, span := startRequestSpan(r)
+	defer func() {
+		observeRequest("v1_extract", w.status())
+		endRequestSpan(span, "v1_extract", w.status())
+	}()
+
+	if r.Method != http.MethodPost {
+		writeDetail(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.apiKey != "" && r.Header.Get("X-API-Key") != s.apiKey {
+		writeDetail(w, http.StatusUnauthorized, "Invalid or expired API key")
+		return
+	}
+	if s.limits.bodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.limits.bodyBytes)
+	}
+	req, texts, herr := s.decodeV1Request(ctx, r)
+	if herr != nil {
+		writeDetail(w, herr.code, herr.msg)
+		return
+	}
+	name := req.Model
+	if p := r.PathValue("model"); p != "" {
+		name = p
+	}
+
+	m := s.acquireOrReply(w, name)
+	if m == nil {
+		return
+	}
+	defer s.releaseModel(m)
+	leave, err := m.enterQueue(ctx)
+	if err != nil {
+		writeBusy(w, err)
+		return
+	}
+	defer leave()
+
+	threshold := float32(0.5)
+	if req.Threshold != nil {
+		threshold = *req.Threshold
+	}
+	results, err := m.extractAll(ctx, texts, req.Tasks, threshold, req.FlatNER)
+	if err != nil {
+		writeDetail(w, extractErrorCode(err), err.Error())
+		return
+	}
+	for _, text := range texts {
+		observeText(text)
+	}
+
+	out := v1Response{Model: m.id(), Results: make([]v1Result, len(results))}
+	for i, res := range results {
+		out.Results[i] = v1Result{ID: req.Documents[i].ID, Result: nonNilResult(res)}
+	}
+	_, espan := tracer.Start(ctx, "response.encode")
+	writeJSON(w, http.StatusOK, out)
+	espan.End()
+}
+
+// decodeV1Request reads and validates a /v1/extract body, filling in default
+// document IDs, and returns it with its texts.
+func (s *server) decodeV1Request(ctx context.Context, r *http.Request) (req v1Request, texts []string, herr *httpError) {
+	_, span := tracer.Start(ctx, "request.decode")
+	defer func() { endSpan(span, herrError(herr)) }()
+
+	if herr := decodeBody(r, &req); herr != nil {
+		return req, nil, herr
+	}
+	switch {
+	case len(req.Tasks) == 0:
+		return req, nil, &httpError{http.StatusUnprocessableEntity, "tasks is required"}
+	case len(req.Documents) == 0:
+		return req, nil, &httpError{http.StatusUnprocessableEntity, "documents is required"}
+	case req.Threshold != nil && (*req.Threshold < 0 || *req.Threshold > 1):
+		return req, nil, &httpError{http.StatusUnprocessableEntity, fmt.Sprintf("threshold %v is outside [0, 1]", *req.Threshold)}
+	}
+	for i, t := range req.Tasks {
+		if !v1TaskTypes[t.Type] {
+			return req, nil, &httpError{http.StatusUnprocessableEntity, fmt.Sprintf("task %d: unknown type %q (want entities, relations, classifications or structure)", i, t.Type)}
+		}
+	}
+
+	seen := make(map[string]bool, len(req.Documents))
+	texts = make([]string, len(req.Documents))
+	for i := range req.Documents {
+		d := &req.Documents[i]
+		if d.ID == "" {
+			d.ID = strconv.Itoa(i)
+		}
+		if seen[d.ID] {
+			return req, nil, &httpError{http.StatusUnprocessableEntity, fmt.Sprintf("duplicate document id %q", d.ID)}
+		}
+		seen[d.ID] = true
+		texts[i] = d.Text
+	}
+	span.SetAttributes(attribute.Int("gliner2.texts", len(texts)), attribute.Int("gliner2.tasks", len(req.Tasks)))
+	if herr := s.limits.checkTexts(texts); herr != nil {
+		return req, nil, herr
+	}
+	return req, texts, s.limits.checkTasks(req.Tasks)
+}
+
+// extractAll runs tasks over every text. Several texts go through one
+// ExtractBatch call, which holds the engine for all of them but still runs
+// them one after another.
+func (m *model) extractAll(ctx context.Context, texts []string, tasks []gliner2.Task, threshold float32, flatNER bool) ([]*gliner2.Result, error) {
+	if len(texts) == 1 {
+		res, err := m.extract(ctx, texts[0], tasks, threshold, flatNER)
+		if err != nil {
+			return nil, err
+		}
+		return []*gliner2.Result{res}, nil
+	}
+	ctx, span := tracer.Start(ctx, "gliner2.ExtractBatch", trace.WithAttributes(
+		attribute.Int("gliner2.batch_size", len(texts)), attribute.Int("gliner2.tasks", len(tasks))))
+	res, err := m.extractBatch(ctx, texts, tasks, threshold, flatNER)
+	endSpan(span, err)
+	return res, err
+}
+
+// nonNilResult returns a copy of res with empty lists in place of nil ones, so
+// every result field is a JSON array. res itself may be shared (e.g. a cached
+// result) and is left as is.
+func nonNilResult(res *gliner2.Result) *gliner2.Result {
+	var out gliner2.Result
+	if res != nil {
+		out = *res
+	}
+	if out.Entities == nil {
+		out.Entities = []gliner2.Entity{}
+	}
+	if out.Relations == nil {
+		out.Relations = []gliner2.Relation{}
+	}
+	if out.Classifications == nil {
+		out.Classifications = []gliner2.Classification{}
+	}
+	if out.Structures == nil {
+		out.Structures = []gliner2.Structure{}
+	}
+	return &out
+}
+
+// herrError adapts an httpError to endSpan.
+func herrError(herr *httpError) error {
+	if herr == nil {
+		return nil
+	}
+	return errors.New(herr.msg)
+}
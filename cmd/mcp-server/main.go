@@ -69,11 +69,22 @@ func runServer() {
 		},
 	})
 
+	registerTools(s, eng)
+
+	fmt.Fprintf(os.Stderr, "Starting MCP server on stdio…\n")
+	if err := s.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
+		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// registerTools adds the extraction tools, backed by ext, to s.
+func registerTools(s *mcp.Server, ext gliner2.Extractor) {
 	mcp.AddTool(s, &mcp.Tool{
 		Name:        "extract_entities",
 		Description: "Extract named entities of the given labels from text using GLiNER2.",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, in ExtractEntitiesInput) (*mcp.CallToolResult, any, error) {
-		res, err := eng.Extract(in.Text, []gliner2.Task{gliner2.Entities(in.Labels...)}, float32(threshold), false)
+		res, err := ext.Extract(in.Text, []gliner2.Task{gliner2.Entities(in.Labels...)}, float32(threshold), false)
 		if err != nil {
 			return errorResult(fmt.Sprintf("inference error: %v", err)), nil, nil
 		}
@@ -88,18 +99,12 @@ func runServer() {
 		for _, rt := range in.RelationTypes {
 			tasks = append(tasks, gliner2.Relations(rt, "head", "tail"))
 		}
-		res, err := eng.Extract(in.Text, tasks, float32(threshold), false)
+		res, err := ext.Extract(in.Text, tasks, float32(threshold), false)
 		if err != nil {
 			return errorResult(fmt.Sprintf("inference error: %v", err)), nil, nil
 		}
 		return jsonResult(res.Relations)
 	})
-
-	fmt.Fprintf(os.Stderr, "Starting MCP server on stdio…\n")
-	if err := s.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
-		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
-		os.Exit(1)
-	}
 }
 
 func jsonResult(v any) (*mcp.CallToolResult, any, error) {
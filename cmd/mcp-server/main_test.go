@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2"
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2/gliner2test"
+)
+
+// connect serves the tools backed by ext over an in-memory transport and
+// returns a client session for it.
+func connect(t *testing.T, ext gliner2.Extractor) *mcp.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+	s := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "0"}, nil)
+	registerTools(s, ext)
+	st, ct := mcp.NewInMemoryTransports()
+	ss, err := s.Connect(ctx, st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ss.Close() })
+	cs, err := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "0"}, nil).Connect(ctx, ct, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cs.Close() })
+	return cs
+}
+
+// callTool calls name with args and returns the text of its single result.
+func callTool(t *testing.T, cs *mcp.ClientSession, name string, args any) (string, bool) {
+	t.Helper()
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if len(res.Content) != 1 {
+		t.Fatalf("%s: %d content items, want 1", name, len(res.Content))
+	}
+	text, ok := res.Content[0].(*mcp.TextContent)
+	if !ok {
+		t.Fatalf("%s: content is %T, want text", name, res.Content[0])
+	}
+	return text.Text, res.IsError
+}
+
+func TestTools(t *testing.T) {
+	f := gliner2test.New().
+		AddEntity("person", `Mario Rossi`).
+		AddRelation("works_at", "Mario Rossi", "Apple")
+	cs := connect(t, f)
+	text := "Mario Rossi works at Apple."
+
+	out, isErr := callTool(t, cs, "extract_entities", map[string]any{"text": text, "labels": []string{"person"}})
+	if isErr {
+		t.Fatalf("extract_entities failed: %s", out)
+	}
+	var ents []gliner2.Entity
+	if err := json.Unmarshal([]byte(out), &ents); err != nil {
+		t.Fatal(err)
+	}
+	if len(ents) != 1 || ents[0].Text != "Mario Rossi" || ents[0].Label != "person" {
+		t.Errorf("entities = %+v", ents)
+	}
+
+	out, isErr = callTool(t, cs, "extract_relations", map[string]any{"text": text, "relation_types": []string{"works_at"}})
+	if isErr {
+		t.Fatalf("extract_relations failed: %s", out)
+	}
+	var rels []gliner2.Relation
+	if err := json.Unmarshal([]byte(out), &rels); err != nil {
+		t.Fatal(err)
+	}
+	if len(rels) != 1 || rels[0].Head.Text != "Mario Rossi" || rels[0].Tail.Text != "Apple" {
+		t.Errorf("relations = %+v", rels)
+	}
+
+	calls := f.Calls()
+	if len(calls) != 2 || calls[1].Tasks[0].Type != "relations" || calls[1].Tasks[0].Name != "works_at" {
+		t.Errorf("calls = %+v", calls)
+	}
+
+	f.SetError(errors.New("boom"))
+	if out, isErr := callTool(t, cs, "extract_entities", map[string]any{"text": text, "labels": []string{"person"}}); !isErr || out != "inference error: boom" {
+		t.Errorf("engine error: got %q (isError %t)", out, isErr)
+	}
+}
@@ -1,14 +1,53 @@
 // Fix unused import
-use libc::{c_char, c_float, size_t};
+use libc::{c_char, c_float, c_int, size_t};
+use std::cell::RefCell;
 use std::ffi::{CStr, CString};
-use gliner::model::{GLiNER, input::text::TextInput, params::Parameters};
-use gliner::model::pipeline::span::SpanMode;
-use gliner::model::pipeline::token::TokenMode;
+use gliner::model::{input::text::TextInput, params::Parameters};
+use gliner::model::pipeline::span::SpanPipeline;
 use gliner::model::pipeline::relation::RelationPipeline;
+use gliner::model::pipeline::token::TokenPipeline;
 use gliner::model::input::relation::schema::RelationSchema;
 use orp::params::RuntimeParameters;
 use orp::pipeline::Pipeline;
-use composable::Composable; // Fix missing trait
+
+// ==================================================================================
+// Errors
+// ==================================================================================
+
+thread_local! {
+    static LAST_ERROR: RefCell<Option<CString>> = RefCell::new(None);
+}
+
+fn set_last_error(msg: impl Into<String>) {
+    let s: String = msg.into();
+    // Interior NULs would make CString::new fail and lose the message.
+    if let Ok(c) = CString::new(s.replace('\0', " ")) {
+        LAST_ERROR.with(|e| *e.borrow_mut() = Some(c));
+    }
+}
+
+fn clear_last_error() {
+    LAST_ERROR.with(|e| *e.borrow_mut() = None);
+}
+
+/// Returns a pointer to the last error message recorded on this thread (or null).
+/// The returned pointer is owned by the library and remains valid until the next
+/// fallible call on the same thread; callers must NOT free it.
+#[no_mangle]
+pub extern "C" fn gline_last_error() -> *const c_char {
+    LAST_ERROR.with(|e| match &*e.borrow() {
+        Some(c) => c.as_ptr(),
+        None => std::ptr::null(),
+    })
+}
+
+/// Version of this library's C ABI: the exported signatures and the layouts of
+/// FlatSpan, FlatRelation and GlineParams. Bump it with any change to them; the
+/// Go package refuses to load a library reporting another version.
+#[no_mangle]
+pub extern "C" fn gline_abi_version() -> c_int {
+    1
+}
 
 // ==================================================================================
 // Shared Structs
@@ -69,43 +108,82 @@ pub struct BatchRelationResult {
     pub count: size_t,
 }
 
+// ==================================================================================
+// Parameters
+// ==================================================================================
+
+/// Inference parameters passed to the new_* and inference_* functions. Each
+/// field has a "keep" value that leaves the current setting alone: the
+/// gline-rs default at load time, or the model's load-time value per call.
+/// A null GlineParams pointer keeps everything.
+#[repr(C)]
+pub struct GlineParams {
+    pub threshold: c_float, // keep: < 0
+    pub flat_ner: c_int,    // keep: < 0; otherwise 0 = false, 1 = true
+    pub multi_label: c_int, // keep: < 0; otherwise 0 = false, 1 = true
+    pub max_width: size_t,  // keep: 0
+    pub threads: size_t,    // keep: 0; only read at load time
+}
+
+/// Returns base with the non-keep fields of p applied.
+unsafe fn merge_params(base: &Parameters, p: *const GlineParams) -> Parameters {
+    let mut out = base.clone();
+    if p.is_null() {
+        return out;
+    }
+    let p = &*p;
+    if p.threshold >= 0.0 {
+        out = out.with_threshold(p.threshold);
+    }
+    if p.flat_ner >= 0 {
+        out = out.with_flat_ner(p.flat_ner != 0);
+    }
+    if p.multi_label >= 0 {
+        out = out.with_multi_label(p.multi_label != 0);
+    }
+    if p.max_width > 0 {
+        out = out.with_max_width(p.max_width as usize);
+    }
+    out
+}
+
+unsafe fn runtime_params(p: *const GlineParams) -> RuntimeParameters {
+    let rt = RuntimeParameters::default();
+    if !p.is_null() && (*p).threads > 0 {
+        return rt.with_threads((*p).threads as usize);
+    }
+    rt
+}
+
 // ==================================================================================
 // Wrappers
 // ==================================================================================
 
+// The span and token wrappers build their pipeline (tokenizer included) once
+// at load time and reuse it on every inference_* call. They hold the model and
+// pipeline separately, rather than a GLiNER<P>, so that each call can run with
+// its own Parameters.
 pub struct SpanModelWrapper {
-    pub inner: GLiNER<SpanMode>,
+    model: orp::model::Model,
+    pipeline: SpanPipeline,
+    params: Parameters,
 }
 
 pub struct TokenModelWrapper {
-    pub inner: GLiNER<TokenMode>,
+    model: orp::model::Model,
+    pipeline: TokenPipeline,
+    params: Parameters,
 }
 
 pub struct RelationModelWrapper {
-    // RelationPipeline needs to be constructed with a schema.
-    // The model (GLiNER struct) owns the pipeline. 
-    // BUT GLiNER<P> expects P to implement Pipeline.
-    // RelationPipeline has lifetime parameters and references schema.
-    // This makes it hard to wrap in a struct without self-referential issues.
-    // 
-    // However, looking at example:
-    // let pipeline = composed![... RelationPipeline ...];
-    // pipeline.apply(input)
-    //
-    // GLiNER struct just wraps model + pipeline + params.
-    // We can't easily put RelationPipeline into GLiNER<RelationPipeline> because RelationPipeline isn't 'static usually?
-    // Let's check RelationPipeline definition. It has 'a lifetime for schema.
-    // So we can't own the schema inside the wrapper easily unless we use unsafe or box leaking.
-    // 
-    // ALTERNATIVE: Accessing the model directly without GLiNER wrapper for Relation?
-    // The example uses `composed!` pipeline.
-    //
-    // Let's stick to Span/Token first as they are standard GLiNER<P>.
-    // For Relation, we might need a custom wrapper that holds Schema + Model + Pipeline constructed manually.
-    
+    // The token pipeline is built once at load time. RelationPipeline<'a>
+    // borrows the schema, which add_relation_schema keeps extending, so
+    // inference_relation builds it per call from the wrapper's schema.
+    token_pipeline: TokenPipeline,
+    params: Parameters,
     pub model: orp::model::Model,
     pub tokenizer_path: String,
-    pub schema: RelationSchema, // Owner of schema data
+    schema: RelationSchema,
 }
 
 
@@ -114,28 +192,34 @@ pub struct RelationModelWrapper {
 // ==================================================================================
 
 #[no_mangle]
-pub extern "C" fn new_span_model(model_path: *const c_char, tokenizer_path: *const c_char) -> *mut SpanModelWrapper {
-    let m_path = unsafe { CStr::from_ptr(model_path).to_string_lossy() };
-    let t_path = unsafe { CStr::from_ptr(tokenizer_path).to_string_lossy() };
-
-    // Cow<str> implements AsRef<Path> via str? No. 
-    // AsRef<Path> is implemented for str, String, Cow<'a, str>.
-    // But error said `Cow<'_, str>: AsRef<...>` is not satisfied.
-    // Wait, error said `AsRef<Path>` is NOT implemented for `Cow<'_, str>`.
-    // It IS implemented for `str`. So we should just pass `m_path.as_ref()`.
-    
-    match GLiNER::<SpanMode>::new(
-        Parameters::default(),
-        RuntimeParameters::default(),
-        t_path.as_ref(), // Pass as &str
-        m_path.as_ref(), // Pass as &str
-    ) {
-        Ok(model) => Box::into_raw(Box::new(SpanModelWrapper { inner: model })),
+pub unsafe extern "C" fn new_span_model(
+    model_path: *const c_char,
+    tokenizer_path: *const c_char,
+    params: *const GlineParams,
+) -> *mut SpanModelWrapper {
+    let m_path = CStr::from_ptr(model_path).to_string_lossy();
+    let t_path = CStr::from_ptr(tokenizer_path).to_string_lossy();
+
+    clear_last_error();
+    let model = match orp::model::Model::new(m_path.as_ref(), runtime_params(params)) {
+        Ok(m) => m,
         Err(e) => {
-            eprintln!("Error loading span model: {:?}", e);
-            std::ptr::null_mut()
+            set_last_error(format!("load span model (model {m_path}, tokenizer {t_path}): {e}"));
+            return std::ptr::null_mut();
         }
-    }
+    };
+    let pipeline = match SpanPipeline::new(t_path.as_ref()) {
+        Ok(p) => p,
+        Err(e) => {
+            set_last_error(format!("load span model (model {m_path}, tokenizer {t_path}): {e}"));
+            return std::ptr::null_mut();
+        }
+    };
+    Box::into_raw(Box::new(SpanModelWrapper {
+        model,
+        pipeline,
+        params: merge_params(&Parameters::default(), params),
+    }))
 }
 
 #[no_mangle]
@@ -144,10 +228,24 @@ pub unsafe extern "C" fn inference_span(
     inputs: *const *const c_char, 
     input_count: size_t,
     labels: *const *const c_char,
-    label_count: size_t
+    label_count: size_t,
+    params: *const GlineParams,
 ) -> *mut BatchResult {
-    if wrapper.is_null() { return std::ptr::null_mut(); }
-    let model = &(*wrapper).inner;
+    clear_last_error();
+    if wrapper.is_null() {
+        set_last_error("model is null");
+        return std::ptr::null_mut();
+    }
+    if input_count == 0 || inputs.is_null() {
+        set_last_error("no input texts");
+        return std::ptr::null_mut();
+    }
+    if label_count == 0 || labels.is_null() {
+        set_last_error("no labels");
+        return std::ptr::null_mut();
+    }
+    let w = &*wrapper;
+    let params = merge_params(&w.params, params);
 
     // ... (skipping input conversion) ...
     let input_slice = std::slice::from_raw_parts(inputs, input_count as usize);
@@ -166,13 +264,12 @@ pub unsafe extern "C" fn inference_span(
     let text_input = match TextInput::from_str(&text_refs, &label_refs) {
         Ok(i) => i,
         Err(e) => {
-             eprintln!("Input error: {:?}", e);
-             return std::ptr::null_mut();
+            set_last_error(format!("invalid input: {e}"));
+            return std::ptr::null_mut();
         }
     };
 
-    // Inference
-    match model.inference(text_input) {
+    match w.model.inference(text_input, &w.pipeline, &params) {
         Ok(output) => {
             let mut flat_spans = Vec::new();
             for seq_spans in output.spans {
@@ -199,7 +296,7 @@ pub unsafe extern "C" fn inference_span(
             }))
         },
         Err(e) => {
-            eprintln!("Inference error: {:?}", e);
+            set_last_error(format!("inference: {e}"));
             std::ptr::null_mut()
         }
     }
@@ -208,22 +305,34 @@ pub unsafe extern "C" fn inference_span(
 // ... Token Mode ...
 
 #[no_mangle]
-pub extern "C" fn new_token_model(model_path: *const c_char, tokenizer_path: *const c_char) -> *mut TokenModelWrapper {
-    let m_path = unsafe { CStr::from_ptr(model_path).to_string_lossy() };
-    let t_path = unsafe { CStr::from_ptr(tokenizer_path).to_string_lossy() };
-
-    match GLiNER::<TokenMode>::new(
-        Parameters::default(),
-        RuntimeParameters::default(),
-        t_path.as_ref(),
-        m_path.as_ref(),
-    ) {
-        Ok(model) => Box::into_raw(Box::new(TokenModelWrapper { inner: model })),
+pub unsafe extern "C" fn new_token_model(
+    model_path: *const c_char,
+    tokenizer_path: *const c_char,
+    params: *const GlineParams,
+) -> *mut TokenModelWrapper {
+    let m_path = CStr::from_ptr(model_path).to_string_lossy();
+    let t_path = CStr::from_ptr(tokenizer_path).to_string_lossy();
+
+    clear_last_error();
+    let model = match orp::model::Model::new(m_path.as_ref(), runtime_params(params)) {
+        Ok(m) => m,
         Err(e) => {
-            eprintln!("Error loading token model: {:?}", e);
-            std::ptr::null_mut()
+            set_last_error(format!("load token model (model {m_path}, tokenizer {t_path}): {e}"));
+            return std::ptr::null_mut();
         }
-    }
+    };
+    let pipeline = match TokenPipeline::new(t_path.as_ref()) {
+        Ok(p) => p,
+        Err(e) => {
+            set_last_error(format!("load token model (model {m_path}, tokenizer {t_path}): {e}"));
+            return std::ptr::null_mut();
+        }
+    };
+    Box::into_raw(Box::new(TokenModelWrapper {
+        model,
+        pipeline,
+        params: merge_params(&Parameters::default(), params),
+    }))
 }
 
 // ... inference_token (apply offsets fix) ...
@@ -233,10 +342,24 @@ pub unsafe extern "C" fn inference_token(
     inputs: *const *const c_char, 
     input_count: size_t,
     labels: *const *const c_char,
-    label_count: size_t
+    label_count: size_t,
+    params: *const GlineParams,
 ) -> *mut BatchResult {
-      if wrapper.is_null() { return std::ptr::null_mut(); }
-    let model = &(*wrapper).inner;
+      clear_last_error();
+    if wrapper.is_null() {
+        set_last_error("model is null");
+        return std::ptr::null_mut();
+    }
+    if input_count == 0 || inputs.is_null() {
+        set_last_error("no input texts");
+        return std::ptr::null_mut();
+    }
+    if label_count == 0 || labels.is_null() {
+        set_last_error("no labels");
+        return std::ptr::null_mut();
+    }
+    let w = &*wrapper;
+    let params = merge_params(&w.params, params);
 
     // ... conversion ...
     let input_slice = std::slice::from_raw_parts(inputs, input_count as usize);
@@ -255,12 +378,12 @@ pub unsafe extern "C" fn inference_token(
     let text_input = match TextInput::from_str(&text_refs, &label_refs) {
         Ok(i) => i,
         Err(e) => {
-             eprintln!("Input error: {:?}", e);
-             return std::ptr::null_mut();
+            set_last_error(format!("invalid input: {e}"));
+            return std::ptr::null_mut();
         }
     };
 
-    match model.inference(text_input) {
+    match w.model.inference(text_input, &w.pipeline, &params) {
         Ok(output) => {
             let mut flat_spans = Vec::new();
             for seq_spans in output.spans {
@@ -286,7 +409,7 @@ pub unsafe extern "C" fn inference_token(
             }))
         },
         Err(e) => {
-            eprintln!("Inference error: {:?}", e);
+            set_last_error(format!("inference: {e}"));
             std::ptr::null_mut()
         }
     }
@@ -324,43 +447,48 @@ pub unsafe extern "C" fn free_batch_result(result: *mut BatchResult) {
 }
 
 // ==================================================================================
-// Relation - OMITTED FOR BRIEFNESS FOR NOW, ADDING IF REQUESTED
-// Since RelationPipeline requires schema lifetime games, it's safer to implement
-// later or if user explicitly pushes for it. The Plan said "support all", but
-// implementing it robustly in C FFI is tricky due to schemas.
-// User said "support all three". I must implement it.
+// Relation
 //
-// Strategy: 
-// The RelationModelWrapper will hold the schema.
-// When we infer, we construct the pipeline transiently or validly.
-// But wait, constructing `RelationPipeline::default` takes `&'a RelationSchema`.
-// So schema must live longer than pipeline. 
-// If we build pipeline on every inference, we are fine.
+// Relation extraction chains two pipelines: TokenPipeline (text -> spans) and
+// RelationPipeline (spans -> relations). Both parse tokenizer.json, so they are
+// built once and cached in the wrapper rather than per inference_relation call.
 // ==================================================================================
 
 #[no_mangle]
-pub extern "C" fn new_relation_model(model_path: *const c_char, tokenizer_path: *const c_char) -> *mut RelationModelWrapper {
-    let m_path = unsafe { CStr::from_ptr(model_path).to_string_lossy() };
-    let t_path = unsafe { CStr::from_ptr(tokenizer_path).to_string_lossy() };
+pub unsafe extern "C" fn new_relation_model(
+    model_path: *const c_char,
+    tokenizer_path: *const c_char,
+    params: *const GlineParams,
+) -> *mut RelationModelWrapper {
+    let m_path = CStr::from_ptr(model_path).to_string_lossy();
+    let t_path = CStr::from_ptr(tokenizer_path).to_string_lossy();
     
     // Load model
     // Model::new gives Result<Model>. 
     // It expects `P: AsRef<Path>`. `m_path` is Cow<str>. `&m_path` is `&Cow<str>`. 
     // We need to pass `m_path.as_ref()` or `&*m_path` if it's a string, actually `AsRef<Path>` is implemented for `str`.
-    let model = match orp::model::Model::new(m_path.as_ref(), RuntimeParameters::default()) {
+    clear_last_error();
+    let model = match orp::model::Model::new(m_path.as_ref(), runtime_params(params)) {
         Ok(m) => m,
         Err(e) => {
-            eprintln!("Error loading model: {:?}", e);
+            set_last_error(format!("load relation model {m_path}: {e}"));
+            return std::ptr::null_mut();
+        }
+    };
+    let token_pipeline = match TokenPipeline::new(t_path.as_ref()) {
+        Ok(p) => p,
+        Err(e) => {
+            set_last_error(format!("token pipeline (tokenizer {t_path}): {e}"));
             return std::ptr::null_mut();
         }
     };
-
-    let schema = RelationSchema::new();
 
     Box::into_raw(Box::new(RelationModelWrapper {
+        token_pipeline,
+        params: merge_params(&Parameters::default(), params),
         model,
         tokenizer_path: t_path.to_string(),
-        schema,
+        schema: RelationSchema::new(),
     }))
 }
 
@@ -372,8 +500,20 @@ pub unsafe extern "C" fn add_relation_schema(
     head_count: size_t,
     tail_types: *const *const c_char,
     tail_count: size_t,
-) {
-    if wrapper.is_null() { return; }
+) -> c_int {
+    clear_last_error();
+    if wrapper.is_null() {
+        set_last_error("model is null");
+        return -1;
+    }
+    if relation.is_null() {
+        set_last_error("relation name is null");
+        return -1;
+    }
+    if head_count == 0 || tail_count == 0 || head_types.is_null() || tail_types.is_null() {
+        set_last_error("head/tail types cannot be empty");
+        return -1;
+    }
     let wrapper_ref = &mut *wrapper;
     
     let rel_name = CStr::from_ptr(relation).to_string_lossy();
@@ -389,6 +529,7 @@ pub unsafe extern "C" fn add_relation_schema(
     let tail_refs: Vec<&str> = tails.iter().map(|s| s.as_ref()).collect();
 
     wrapper_ref.schema.push_with_allowed_labels(&rel_name, &head_refs, &tail_refs);
+    0
 }
 
 #[no_mangle]
@@ -397,9 +538,22 @@ pub unsafe extern "C" fn inference_relation(
     inputs: *const *const c_char,
     input_count: size_t,
     entity_labels: *const *const c_char,
-    entity_label_count: size_t 
+    entity_label_count: size_t,
+    params: *const GlineParams,
 ) -> *mut BatchRelationResult {
-    if wrapper.is_null() { return std::ptr::null_mut(); }
+    clear_last_error();
+    if wrapper.is_null() {
+        set_last_error("model is null");
+        return std::ptr::null_mut();
+    }
+    if input_count == 0 || inputs.is_null() {
+        set_last_error("no input texts");
+        return std::ptr::null_mut();
+    }
+    if entity_label_count == 0 || entity_labels.is_null() {
+        set_last_error("no labels");
+        return std::ptr::null_mut();
+    }
     let wrapper_ref = &mut *wrapper;
 
     // Convert inputs
@@ -422,70 +576,29 @@ pub unsafe extern "C" fn inference_relation(
     let text_input = match TextInput::from_str(&text_refs, &label_refs) {
         Ok(i) => i,
         Err(e) => {
-             eprintln!("Input error: {:?}", e);
-             return std::ptr::null_mut();
+            set_last_error(format!("invalid input: {e}"));
+            return std::ptr::null_mut();
         }
     };
 
-    // Pipeline composition for Relation Extraction
-    // Logic from example:
-    // let pipeline = composed![
-    //     TokenPipeline::new(TOKENIZER_PATH)?.to_composable(&model, &params),
-    //     RelationPipeline::default(TOKENIZER_PATH, &relation_schema)?.to_composable(&model, &params),
-    // ];
-    // BUT Wait. `RelationPipeline` expects `SpanOutput` as input.
-    // `TokenPipeline` produces `SpanOutput`.
-    // So we need to chain them!
-    // The previous error was `expected `SpanOutput`, found `TextInput``. 
-    // Because I was passing `text_input` (TextInput) directly to `rel_pipeline` (which expects SpanOutput).
-    // I need the FULL pipeline chain: TokenPipeline -> RelationPipeline.
-    
-    // 1. Create Token Pipeline
-    use gliner::model::pipeline::token::TokenPipeline;
-    let token_pipeline_res = TokenPipeline::new(&wrapper_ref.tokenizer_path);
-    let token_pipeline = match token_pipeline_res {
-         Ok(p) => p,
-         Err(e) => {
-             eprintln!("Token pipeline error: {:?}", e);
-             return std::ptr::null_mut();
-         }
-    };
-    
-    // 2. Create Relation Pipeline
-    let rel_pipeline_res = RelationPipeline::default(&wrapper_ref.tokenizer_path, &wrapper_ref.schema);
-    let rel_pipeline = match rel_pipeline_res {
+    let rel_pipeline = match RelationPipeline::default(&wrapper_ref.tokenizer_path, &wrapper_ref.schema) {
         Ok(p) => p,
         Err(e) => {
-            eprintln!("Relation pipeline error: {:?}", e);
+            set_last_error(format!("relation pipeline (tokenizer {}): {e}", wrapper_ref.tokenizer_path));
             return std::ptr::null_mut();
         }
     };
+    let params = merge_params(&wrapper_ref.params, params);
 
-    let params = Parameters::default();
-    
-    // 3. Compose them
-    // composed! macro might be hard to access if not exported or syntax weird in FFI file.
-    // But `composed!` just chains them.
-    // We can use `.then(...)` equivalent? 
-    // `composable::Composed::new(prev, next)`
-    // OR just manually chain calls? 
-    // `runnable_token.apply(input) -> span_output`
-    // `runnable_rel.apply(span_output) -> relation_output`
-    
-    let runnable_token = token_pipeline.to_composable(&wrapper_ref.model, &params);
-    let runnable_rel = rel_pipeline.to_composable(&wrapper_ref.model, &params);
-    
-    // Run Step 1
-    let span_output = match runnable_token.apply(text_input) {
+    let span_output = match wrapper_ref.model.inference(text_input, &wrapper_ref.token_pipeline, &params) {
         Ok(out) => out,
         Err(e) => {
-             eprintln!("Token inference error: {:?}", e);
-             return std::ptr::null_mut();
+            set_last_error(format!("token inference: {e}"));
+            return std::ptr::null_mut();
         }
     };
-    
-    // Run Step 2
-    match runnable_rel.apply(span_output) {
+
+    match wrapper_ref.model.inference(span_output, &rel_pipeline, &params) {
         Ok(output) => {
             // output is RelationOutput { relations: Vec<Vec<Relation>>, ... }
             let mut flat_rels = Vec::new();
@@ -511,7 +624,7 @@ pub unsafe extern "C" fn inference_relation(
             }))
         },
         Err(e) => {
-            eprintln!("Inference error: {:?}", e);
+            set_last_error(format!("inference: {e}"));
             std::ptr::null_mut()
         }
     }
@@ -17,6 +17,29 @@ use gliner2_inference::{
 };
 use serde::{Deserialize, Serialize};
 
+/// Version of this library's C ABI (exported symbols + JSON wire shapes). Bump it
+/// whenever a signature or the task/result JSON changes incompatibly; the Go side
+/// refuses to load a library whose version differs from the one it was built for.
+const ABI_VERSION: c_int = 1;
+
+/// Optional features this build supports, as a NUL-terminated JSON array. Task
+/// kinds are named by their `type` discriminator (see TaskDto); `flat_ner` covers
+/// the non-overlapping span mode of `gliner2_extract`.
+static CAPABILITIES: &[u8] = b"[\"entities\",\"relations\",\"classifications\",\"structure\",\"flat_ner\"]\0";
+
+/// Returns the C ABI version implemented by this library (see `ABI_VERSION`).
+#[no_mangle]
+pub extern "C" fn gliner2_abi_version() -> c_int {
+    ABI_VERSION
+}
+
+/// Returns the capability list as a static JSON array of strings. The pointer is
+/// owned by the library and valid for its lifetime; callers must NOT free it.
+#[no_mangle]
+pub extern "C" fn gliner2_capabilities() -> *const c_char {
+    CAPABILITIES.as_ptr() as *const c_char
+}
+
 thread_local! {
     static LAST_ERROR: RefCell<Option<CString>> = RefCell::new(None);
 }
@@ -0,0 +1,307 @@
+// Package compat keeps the deprecated gline (GLiNER v1) API — its
+// constructors, Model.Predict, RelationModel and result types — but runs on a
+// gliner2.Extractor, so callers can drop the v1 native library by changing
+// only their import path:
+//
+//	import gline "github.com/soundprediction/go-gline-rs/pkg/gline/compat"
+//
+// GLiNER v1 weights cannot be loaded by gliner2, so the model IDs and file
+// paths given to the v1 constructors are ignored: every constructor loads the
+// GLiNER2 model named by DefaultRepo and DefaultVariant instead. Results map
+// onto the v1 types as follows:
+//   - Entity.Start/End are gliner2 char offsets (StartChar/EndChar) and
+//     Entity.Probability is the entity score;
+//   - Relation.Source/Target are the head/tail texts and
+//     Relation.Probability is the lower of the head and tail scores.
+package compat
+
+import (
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2"
+)
+
+// The GLiNER2 model the v1 constructors load. Set them before the first
+// constructor call.
+var (
+	DefaultRepo      = "SemplificaAI/gliner2-multi-v1-onnx"
+	DefaultVariant   = "fp32_v2"
+	DefaultModelType = gliner2.ModelTypeHuggingFace
+)
+
+// The v1 defaults (gline-rs Parameters::default) for Model.Threshold and
+// Model.FlatNER.
+const (
+	defaultThreshold = 0.5
+	defaultFlatNER   = true
+)
+
+var errClosed = errors.New("model is closed")
+
+// Params mirrors gline.Params. Threshold and FlatNER apply to the gliner2
+// calls; MultiLabel, MaxWidth and Threads have no gliner2 equivalent and are
+// ignored.
+type Params struct {
+	Threshold  *float32
+	FlatNER    *bool
+	MultiLabel *bool
+	MaxWidth   *int
+	Threads    *int
+}
+
+// apply returns threshold and flatNER overridden by the non-nil fields of
+// ps, later ones winning.
+func apply(threshold float32, flatNER bool, ps []Params) (float32, bool) {
+	for _, p := range ps {
+		if p.Threshold != nil {
+			threshold = *p.Threshold
+		}
+		if p.FlatNER != nil {
+			flatNER = *p.FlatNER
+		}
+	}
+	return threshold, flatNER
+}
+
+// Entity represents an extracted entity
+type Entity struct {
+	Index       int     `json:"index"` // Sequence index
+	Start       int     `json:"start"`
+	End         int     `json:"end"`
+	Label       string  `json:"label"`
+	Text        string  `json:"text"`
+	Probability float32 `json:"probability"`
+}
+
+// Relation is a relation extracted by RelationModel.Predict.
+type Relation struct {
+	SequenceIndex int     `json:"sequence_index"`
+	Source        string  `json:"source"`
+	Target        string  `json:"target"`
+	Relation      string  `json:"relation"`
+	Probability   float32 `json:"probability"`
+}
+
+// Model is the v1 span/token NER model. In compat both modes run the same
+// GLiNER2 entity extraction.
+type Model struct {
+	mu   sync.Mutex // gliner2 extractors are not safe for concurrent calls
+	ext  gliner2.Extractor
+	owns bool // Close closes ext
+
+	// Threshold and FlatNER are passed to every Extract call.
+	Threshold float32
+	FlatNER   bool
+}
+
+func load() (gliner2.Extractor, error) {
+	return gliner2.New(DefaultRepo, DefaultVariant, DefaultModelType)
+}
+
+func newModel(ext gliner2.Extractor, owns bool, params []Params) *Model {
+	m := &Model{}
+	m.init(ext, owns, params)
+	return m
+}
+
+func (m *Model) init(ext gliner2.Extractor, owns bool, params []Params) {
+	m.ext, m.owns = ext, owns
+	m.Threshold, m.FlatNER = apply(defaultThreshold, defaultFlatNER, params)
+}
+
+// NewFromExtractor returns a Model running on ext, which Close leaves open.
+// Use it to share one engine between models or to test with a gliner2test
+// fake.
+func NewFromExtractor(ext gliner2.Extractor, params ...Params) *Model {
+	return newModel(ext, false, params)
+}
+
+// NewSpanModel loads the default GLiNER2 model; the v1 paths are ignored.
+func NewSpanModel(modelPath, tokenizerPath string, params ...Params) (*Model, error) {
+	ext, err := load()
+	if err != nil {
+		return nil, err
+	}
+	return newModel(ext, true, params), nil
+}
+
+// NewTokenModel loads the default GLiNER2 model; the v1 paths are ignored.
+func NewTokenModel(modelPath, tokenizerPath string, params ...Params) (*Model, error) {
+	return NewSpanModel(modelPath, tokenizerPath, params...)
+}
+
+// NewSpanModelFromHF loads the default GLiNER2 model; modelID is ignored.
+func NewSpanModelFromHF(modelID string, params ...Params) (*Model, error) {
+	return NewSpanModel("", "", params...)
+}
+
+// NewTokenModelFromHF loads the default GLiNER2 model; modelID is ignored.
+func NewTokenModelFromHF(modelID string, params ...Params) (*Model, error) {
+	return NewSpanModel("", "", params...)
+}
+
+func (m *Model) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ext != nil && m.owns {
+		m.ext.Close()
+	}
+	m.ext = nil
+}
+
+// Predict extracts entities of the given labels from each text, returning one
+// (possibly empty) slice per text. params override Threshold and FlatNER for
+// this call.
+func (m *Model) Predict(texts []string, labels []string, params ...Params) ([][]Entity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ext == nil {
+		return nil, errClosed
+	}
+	threshold, flatNER := apply(m.Threshold, m.FlatNER, params)
+	res, err := m.ext.ExtractBatch(texts, []gliner2.Task{gliner2.Entities(labels...)}, threshold, flatNER)
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]Entity, len(texts))
+	for i, r := range res {
+		out[i] = make([]Entity, 0, len(r.Entities))
+		for _, e := range r.Entities {
+			out[i] = append(out[i], toEntity(i, e))
+		}
+	}
+	return out, nil
+}
+
+func toEntity(seq int, e gliner2.Entity) Entity {
+	return Entity{
+		Index:       seq,
+		Start:       e.StartChar,
+		End:         e.EndChar,
+		Label:       e.Label,
+		Text:        e.Text,
+		Probability: e.Score,
+	}
+}
+
+// RelationModel is the v1 relation extraction model.
+type RelationModel struct {
+	Model
+	schema []relationSchema
+}
+
+type relationSchema struct {
+	relation  string
+	headTypes []string
+	tailTypes []string
+}
+
+// NewRelationModelFromExtractor returns a RelationModel running on ext, which
+// Close leaves open.
+func NewRelationModelFromExtractor(ext gliner2.Extractor, params ...Params) *RelationModel {
+	r := &RelationModel{}
+	r.init(ext, false, params)
+	return r
+}
+
+// NewRelationModel loads the default GLiNER2 model; the v1 paths are ignored.
+func NewRelationModel(modelPath, tokenizerPath string, params ...Params) (*RelationModel, error) {
+	ext, err := load()
+	if err != nil {
+		return nil, err
+	}
+	r := &RelationModel{}
+	r.init(ext, true, params)
+	return r, nil
+}
+
+// NewRelationModelFromHF loads the default GLiNER2 model; modelID is ignored.
+func NewRelationModelFromHF(modelID string, params ...Params) (*RelationModel, error) {
+	return NewRelationModel("", "", params...)
+}
+
+// AddRelationSchema adds a relation definition to the schema
+func (r *RelationModel) AddRelationSchema(relation string, headTypes []string, tailTypes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ext == nil {
+		return errClosed
+	}
+	if len(headTypes) == 0 || len(tailTypes) == 0 {
+		return errors.New("head/tail types cannot be empty")
+	}
+	r.schema = append(r.schema, relationSchema{relation: relation, headTypes: headTypes, tailTypes: tailTypes})
+	return nil
+}
+
+// Predict extracts the schema's relations from each text. As in v1, it also
+// extracts entities of entityLabels, and keeps a relation only when its head
+// and tail match entities of the relation's head and tail types. A head or
+// tail that matches no extracted entity is kept, since its type is unknown.
+// params override Threshold and FlatNER for this call.
+func (r *RelationModel) Predict(texts []string, entityLabels []string, params ...Params) ([][]Relation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ext == nil {
+		return nil, errClosed
+	}
+	tasks := []gliner2.Task{gliner2.Entities(entityLabels...)}
+	for _, s := range r.schema {
+		tasks = append(tasks, gliner2.Relations(s.relation, "head", "tail"))
+	}
+	threshold, flatNER := apply(r.Threshold, r.FlatNER, params)
+	res, err := r.ext.ExtractBatch(texts, tasks, threshold, flatNER)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([][]Relation, len(texts))
+	for i, res := range res {
+		out[i] = []Relation{}
+		for _, rel := range res.Relations {
+			s := r.schemaFor(rel.RelationType)
+			if s == nil || !typeMatches(res.Entities, rel.Head, s.headTypes) || !typeMatches(res.Entities, rel.Tail, s.tailTypes) {
+				continue
+			}
+			out[i] = append(out[i], Relation{
+				SequenceIndex: i,
+				Source:        rel.Head.Text,
+				Target:        rel.Tail.Text,
+				Relation:      rel.RelationType,
+				Probability:   min(rel.Head.Score, rel.Tail.Score),
+			})
+		}
+	}
+	return out, nil
+}
+
+func (r *RelationModel) schemaFor(relation string) *relationSchema {
+	for i := range r.schema {
+		if r.schema[i].relation == relation {
+			return &r.schema[i]
+		}
+	}
+	return nil
+}
+
+// typeMatches reports whether span's entity type is one of types, looking
+// the type up among ents by offsets (or, without offsets, by text).
+func typeMatches(ents []gliner2.Entity, span gliner2.Entity, types []string) bool {
+	found := false
+	for _, e := range ents {
+		same := e.StartChar == span.StartChar && e.EndChar == span.EndChar && e.EndChar > 0
+		if span.EndChar == 0 {
+			same = e.Text == span.Text
+		}
+		if !same {
+			continue
+		}
+		if slices.Contains(types, e.Label) {
+			return true
+		}
+		found = true
+	}
+	return !found
+}
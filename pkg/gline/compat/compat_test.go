@@ -0,0 +1,85 @@
+package compat
+
+import (
+	"testing"
+
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2"
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2/gliner2test"
+)
+
+func TestPredict(t *testing.T) {
+	f := gliner2test.New().AddEntityScore("person", `Mario Rossi`, 0.9)
+	m := NewFromExtractor(f)
+	out, err := m.Predict([]string{"no one here", "I met Mario Rossi."}, []string{"person"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Entity{Index: 1, Start: 6, End: 17, Label: "person", Text: "Mario Rossi", Probability: 0.9}
+	if len(out) != 2 || len(out[0]) != 0 || len(out[1]) != 1 || out[1][0] != want {
+		t.Errorf("Predict = %+v, want [[] [%+v]]", out, want)
+	}
+	if calls := f.Calls(); calls[0].Threshold != 0.5 || !calls[0].FlatNER {
+		t.Errorf("call = %+v, want the v1 defaults (0.5, flat NER)", calls[0])
+	}
+
+	no, low := false, float32(0.3)
+	m2 := NewFromExtractor(f, Params{Threshold: &low})
+	if _, err := m2.Predict([]string{"x"}, []string{"person"}, Params{FlatNER: &no}); err != nil {
+		t.Fatal(err)
+	}
+	if calls := f.Calls(); calls[len(calls)-1].Threshold != 0.3 || calls[len(calls)-1].FlatNER {
+		t.Errorf("call = %+v, want the Params overrides (0.3, nested NER)", calls[len(calls)-1])
+	}
+	var zero float32
+	if _, err := m2.Predict([]string{"x"}, []string{"person"}, Params{Threshold: &zero}); err != nil {
+		t.Fatal(err)
+	}
+	if calls := f.Calls(); calls[len(calls)-1].Threshold != 0 {
+		t.Errorf("call threshold = %v, want 0", calls[len(calls)-1].Threshold)
+	}
+
+	m.Close()
+	if f.Closed() {
+		t.Error("Close closed a caller-supplied extractor")
+	}
+	if _, err := m.Predict([]string{"x"}, []string{"person"}); err == nil {
+		t.Error("Predict after Close: want error")
+	}
+}
+
+func TestRelationPredict(t *testing.T) {
+	text := "Mario Rossi works at Apple in Cupertino."
+	f := gliner2test.New().SetResult(text, &gliner2.Result{
+		Entities: []gliner2.Entity{
+			{Text: "Mario Rossi", Label: "person", StartChar: 0, EndChar: 11, Score: 0.9},
+			{Text: "Apple", Label: "organization", StartChar: 21, EndChar: 26, Score: 0.8},
+			{Text: "Cupertino", Label: "location", StartChar: 30, EndChar: 39, Score: 0.8},
+		},
+		Relations: []gliner2.Relation{
+			{RelationType: "works_at", Head: gliner2.Entity{Text: "Mario Rossi", StartChar: 0, EndChar: 11, Score: 0.9}, Tail: gliner2.Entity{Text: "Apple", StartChar: 21, EndChar: 26, Score: 0.7}},
+			// Wrong tail type for works_at: dropped.
+			{RelationType: "works_at", Head: gliner2.Entity{Text: "Mario Rossi", StartChar: 0, EndChar: 11, Score: 0.9}, Tail: gliner2.Entity{Text: "Cupertino", StartChar: 30, EndChar: 39, Score: 0.6}},
+			// Not in the schema: dropped.
+			{RelationType: "born_in", Head: gliner2.Entity{Text: "Mario Rossi"}, Tail: gliner2.Entity{Text: "Cupertino"}},
+		},
+	})
+	r := NewRelationModelFromExtractor(f)
+	if err := r.AddRelationSchema("works_at", []string{"person"}, nil); err == nil {
+		t.Error("AddRelationSchema with no tail types: want error")
+	}
+	if err := r.AddRelationSchema("works_at", []string{"person"}, []string{"organization"}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := r.Predict([]string{text}, []string{"person", "organization", "location"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Relation{SequenceIndex: 0, Source: "Mario Rossi", Target: "Apple", Relation: "works_at", Probability: 0.7}
+	if len(out) != 1 || len(out[0]) != 1 || out[0][0] != want {
+		t.Errorf("Predict = %+v, want [[%+v]]", out, want)
+	}
+	tasks := f.Calls()[0].Tasks
+	if len(tasks) != 2 || tasks[1].Type != "relations" || tasks[1].Name != "works_at" {
+		t.Errorf("tasks = %+v", tasks)
+	}
+}
@@ -1,17 +1,54 @@
 package gline
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/gomlx/go-huggingface/hub"
 )
 
+// DownloadOptions configures DownloadModelWithOptions. The zero value behaves
+// like DownloadModel with an empty cacheDir.
+type DownloadOptions struct {
+	// CacheDir is where files are stored; default ~/.cache/gline-rs.
+	CacheDir string
+	// Revision is the branch, tag or commit hash to fetch; default "main".
+	// Pin a commit hash so upstream updates cannot change the model. It must
+	// not contain path separators or be "." or "..".
+	Revision string
+	// ModelFile is the ONNX file to load, e.g. "model_quantized.onnx". A bare
+	// file name is also looked for under onnx/. Default: model.onnx, then
+	// onnx/model.onnx.
+	ModelFile string
+	// SHA256 is the expected hex digest of the model file. When set, a
+	// mismatch is an error. Online, the snapshot's link to the file is also
+	// removed so the revision stops resolving to it; the blob it points to is
+	// kept, as other snapshots may share it. Offline, nothing is removed.
+	SHA256 string
+	// Offline resolves the files from CacheDir only, without network access.
+	// The revision must have been downloaded before.
+	Offline bool
+}
+
 // DownloadModel downloads the model.onnx and tokenizer.json from Hugging Face
 // modelID: e.g. "onnx-community/gliner_medium-v2.1"
 // cacheDir: directory to store the model. If empty, uses ~/.cache/gline-rs
 func DownloadModel(modelID, cacheDir string) (modelPath, tokenizerPath string, err error) {
+	return DownloadModelWithOptions(modelID, DownloadOptions{CacheDir: cacheDir})
+}
+
+// DownloadModelWithOptions is DownloadModel with a pinned revision, a choice
+// of model file, an integrity check and an offline mode; see DownloadOptions.
+func DownloadModelWithOptions(modelID string, opts DownloadOptions) (modelPath, tokenizerPath string, err error) {
+	cacheDir := opts.CacheDir
 	if cacheDir == "" {
 		home, err := os.UserHomeDir()
 		if err != nil {
@@ -19,47 +56,118 @@ func DownloadModel(modelID, cacheDir string) (modelPath, tokenizerPath string, e
 		}
 		cacheDir = filepath.Join(home, ".cache", "gline-rs")
 	}
+	revision := opts.Revision
+	if revision == "" {
+		revision = "main"
+	}
+	// The revision names a file under the cache's info directory.
+	if strings.ContainsAny(revision, `/\`) || revision == "." || revision == ".." {
+		return "", "", fmt.Errorf("model %s: invalid revision %q", modelID, revision)
+	}
 
-	// Create a new Hub client pointing to our cache directory
-	// Note: go-huggingface might download to a specific structure.
-	// Let's assume hub.New(cacheDir) or similar.
-	// Checking the library usage from common patterns:
-	// repo := hub.New(hub.WithCacheDir(cacheDir))
-	// path, err := repo.DownloadFile(modelID, "model.onnx")
-
-	// Actually, without seeing the docs, I'll guess standard usage or try to find it.
-	// Wait, I can try to run a small script to check the API if I'm unsure.
-	// But let's try to write investigating code.
-
-	// I'll try to use the `hub` package.
-	repo := hub.New(modelID).WithCacheDir(cacheDir)
-
-	// Sanitize modelID for local path check (optional, hub handles it)
-	// safeModelID := strings.ReplaceAll(modelID, "/", "_")
-
-	// Download model.onnx
-	// Try "model.onnx" first, then "onnx/model.onnx" as fallback if possible.
+	fetch := hub.New(modelID).WithCacheDir(cacheDir).WithRevision(revision).DownloadFile
+	if opts.Offline {
+		snapshot, err := cachedSnapshot(cacheDir, modelID, revision)
+		if err != nil {
+			return "", "", err
+		}
+		fetch = func(file string) (string, error) {
+			p := filepath.Join(snapshot, filepath.FromSlash(file))
+			if _, err := os.Stat(p); err != nil {
+				return "", err
+			}
+			return p, nil
+		}
+	}
 
-	// Download functions usually return the absolute path.
-	modelPath, err = repo.DownloadFile("model.onnx")
+	candidates := modelFiles(opts.ModelFile)
+	var errs []error
+	for _, file := range candidates {
+		if modelPath, err = fetch(file); err == nil {
+			break
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", file, err))
+	}
 	if err != nil {
-		// Try onnx/model.onnx in subfolder
-		if mp, err2 := repo.DownloadFile("onnx/model.onnx"); err2 == nil {
-			modelPath = mp
-			err = nil
-		} else {
-			// Check if it was a "not found" error vs connectivity error?
-			// For simplicity, we assume if both fail and we have connectivity, it's incompatible.
-			return "", "", fmt.Errorf("model %s is not compatible: model.onnx not found. Please ensure the model contains 'model.onnx' or 'onnx/model.onnx'", modelID)
+		return "", "", fmt.Errorf("model %s: no model file at revision %s (tried %s): %w", modelID, revision, strings.Join(candidates, ", "), errors.Join(errs...))
+	}
+
+	if opts.SHA256 != "" {
+		if err := verifySHA256(modelPath, opts.SHA256); err != nil {
+			if !opts.Offline {
+				if rerr := unlinkSnapshot(modelPath); rerr != nil {
+					err = errors.Join(err, rerr)
+				}
+			}
+			return "", "", err
 		}
 	}
 
-	// Download tokenizer.json
-	tokenizerPath, err = repo.DownloadFile("tokenizer.json")
+	tokenizerPath, err = fetch("tokenizer.json")
 	if err != nil {
-		// Try onnx/tokenizer.json just in case? Or just error.
 		return "", "", fmt.Errorf("failed to download tokenizer.json: %w", err)
 	}
 
 	return modelPath, tokenizerPath, nil
 }
+
+// modelFiles returns the repo paths to try, in order, for the model file.
+func modelFiles(file string) []string {
+	switch {
+	case file == "":
+		return []string{"model.onnx", "onnx/model.onnx"}
+	case strings.Contains(file, "/"):
+		return []string{file}
+	default:
+		return []string{file, "onnx/" + file}
+	}
+}
+
+// cachedSnapshot returns the snapshot directory of modelID at revision in
+// cacheDir's Hugging Face layout, without network access: info/<revision>
+// records the commit hash, whose files are under snapshots/<hash>.
+func cachedSnapshot(cacheDir, modelID, revision string) (string, error) {
+	repoDir := filepath.Join(cacheDir, "models"+hub.RepoIdSeparator+strings.ReplaceAll(modelID, "/", hub.RepoIdSeparator))
+	hash := revision
+	if b, err := os.ReadFile(filepath.Join(repoDir, "info", revision)); err == nil {
+		var info hub.RepoInfo
+		if err := json.Unmarshal(b, &info); err != nil {
+			return "", fmt.Errorf("offline: cached info for %s@%s: %w", modelID, revision, err)
+		}
+		hash = info.CommitHash
+	}
+	snapshot := filepath.Join(repoDir, "snapshots", hash)
+	if fi, err := os.Stat(snapshot); hash == "" || err != nil || !fi.IsDir() {
+		return "", fmt.Errorf("offline: %s@%s is not in cache %s", modelID, revision, cacheDir)
+	}
+	return snapshot, nil
+}
+
+// unlinkSnapshot removes path from its hub cache snapshot when it is a
+// symlink. It never deletes the blob behind it, nor a regular file.
+func unlinkSnapshot(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("unlink cached %s: %w", path, err)
+	}
+	return nil
+}
+
+func verifySHA256(path, want string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("hash %s: %w", path, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		return fmt.Errorf("model file %s: sha256 %s, want %s", path, got, want)
+	}
+	return nil
+}
@@ -9,6 +9,10 @@ static void* _gl_open_lib(const char* path) {
     return dlopen(path, RTLD_LAZY | RTLD_GLOBAL);
 }
 
+static void _gl_close_lib(void* handle) {
+    dlclose(handle);
+}
+
 static char* _gl_get_dlerror() {
     return dlerror();
 }
@@ -17,21 +21,21 @@ static void* _gl_get_sym(void* handle, const char* name) {
     return dlsym(handle, name);
 }
 
-void* _gl_call_new_span_model(void* f, const char* m, const char* t) {
-    return ((new_span_model_t)f)(m, t);
+void* _gl_call_new_span_model(void* f, const char* m, const char* t, const GlineParams* p) {
+    return ((new_span_model_t)f)(m, t, p);
 }
-BatchResult* _gl_call_inference_span(void* f, void* w, const char** i, size_t ic, const char** l, size_t lc) {
-    return ((inference_span_t)f)(w, i, ic, l, lc);
+BatchResult* _gl_call_inference_span(void* f, void* w, const char** i, size_t ic, const char** l, size_t lc, const GlineParams* p) {
+    return ((inference_span_t)f)(w, i, ic, l, lc, p);
 }
 void _gl_call_free_span_model(void* f, void* w) {
     ((free_span_model_t)f)(w);
 }
 
-void* _gl_call_new_token_model(void* f, const char* m, const char* t) {
-    return ((new_token_model_t)f)(m, t);
+void* _gl_call_new_token_model(void* f, const char* m, const char* t, const GlineParams* p) {
+    return ((new_token_model_t)f)(m, t, p);
 }
-BatchResult* _gl_call_inference_token(void* f, void* w, const char** i, size_t ic, const char** l, size_t lc) {
-    return ((inference_token_t)f)(w, i, ic, l, lc);
+BatchResult* _gl_call_inference_token(void* f, void* w, const char** i, size_t ic, const char** l, size_t lc, const GlineParams* p) {
+    return ((inference_token_t)f)(w, i, ic, l, lc, p);
 }
 void _gl_call_free_token_model(void* f, void* w) {
     ((free_token_model_t)f)(w);
@@ -41,14 +45,14 @@ void _gl_call_free_batch_result(void* f, BatchResult* r) {
     ((free_batch_result_t)f)(r);
 }
 
-void* _gl_call_new_relation_model(void* f, const char* m, const char* t) {
-    return ((new_relation_model_t)f)(m, t);
+void* _gl_call_new_relation_model(void* f, const char* m, const char* t, const GlineParams* p) {
+    return ((new_relation_model_t)f)(m, t, p);
 }
-void _gl_call_add_relation_schema(void* f, void* w, const char* r, const char** ht, size_t hc, const char** tt, size_t tc) {
-    ((add_relation_schema_t)f)(w, r, ht, hc, tt, tc);
+int _gl_call_add_relation_schema(void* f, void* w, const char* r, const char** ht, size_t hc, const char** tt, size_t tc) {
+    return ((add_relation_schema_t)f)(w, r, ht, hc, tt, tc);
 }
-BatchRelationResult* _gl_call_inference_relation(void* f, void* w, const char** i, size_t ic, const char** el, size_t elc) {
-    return ((inference_relation_t)f)(w, i, ic, el, elc);
+BatchRelationResult* _gl_call_inference_relation(void* f, void* w, const char** i, size_t ic, const char** el, size_t elc, const GlineParams* p) {
+    return ((inference_relation_t)f)(w, i, ic, el, elc, p);
 }
 void _gl_call_free_relation_model(void* f, void* w) {
     ((free_relation_model_t)f)(w);
@@ -56,17 +60,25 @@ void _gl_call_free_relation_model(void* f, void* w) {
 void _gl_call_free_relation_result(void* f, BatchRelationResult* r) {
     ((free_relation_result_t)f)(r);
 }
+const char* _gl_call_last_error(void* f) {
+    return ((gline_last_error_t)f)();
+}
+int _gl_call_abi_version(void* f) {
+    return ((gline_abi_version_t)f)();
+}
 */
 import "C"
 import (
 	"compress/gzip"
 	"embed"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"unsafe"
 )
 
@@ -74,7 +86,9 @@ import (
 var libFS embed.FS
 
 var (
-	initialized bool
+	initMu      sync.Mutex
+	initialized bool   // guarded by initMu
+	libDir      string // temp dir holding the extracted library; guarded by initMu
 	dlHandle    unsafe.Pointer
 
 	// Span
@@ -96,6 +110,9 @@ var (
 	fnInferenceRelation  unsafe.Pointer
 	fnFreeRelationModel  unsafe.Pointer
 	fnFreeRelationResult unsafe.Pointer
+
+	// Errors
+	fnLastError unsafe.Pointer
 )
 
 // extractAndDecompress extracts a file from embed.FS
@@ -128,12 +145,36 @@ func extractAndDecompress(srcPath, destPath string) error {
 	return nil
 }
 
-// Init extracts and loads the library
+// abiVersion is the gline_binding C ABI version (gline_abi_version) this
+// package is written against: the layouts of FlatSpan, FlatRelation and
+// GlineParams and the exported signatures. Init refuses any other version, as
+// reading results through a different layout would corrupt memory.
+const abiVersion = 1
+
+// errNotInitialized is returned by the constructors before Init succeeds.
+var errNotInitialized = errors.New("library not initialized; call gline.Init first")
+
+// Init extracts and loads the native library. It must succeed before any
+// model is created. It is safe for concurrent use; once it has succeeded,
+// later calls return nil immediately, while a failed attempt is retried by
+// the next call.
 func Init() error {
+	initMu.Lock()
+	defer initMu.Unlock()
 	if initialized {
 		return nil
 	}
+	if err := load(); err != nil {
+		unload()
+		return err
+	}
+	initialized = true
+	return nil
+}
 
+// load extracts the library for this platform into a fresh temp dir, opens it
+// and resolves its symbols. On error, the caller runs unload.
+func load() error {
 	goOS := runtime.GOOS
 	goArch := runtime.GOARCH
 
@@ -161,6 +202,7 @@ func Init() error {
 	if err != nil {
 		return fmt.Errorf("failed to create temp dir: %w", err)
 	}
+	libDir = tmpDir
 
 	dest := filepath.Join(tmpDir, strings.TrimSuffix(libName, ".gz"))
 	if err := extractAndDecompress(filepath.Join(libPath, libName), dest); err != nil {
@@ -194,6 +236,16 @@ func Init() error {
 		return sym, nil
 	}
 
+	// Check the ABI before trusting any other symbol: an older build exports
+	// the same names with different struct layouts.
+	fnVersion, err := loadSym("gline_abi_version")
+	if err != nil {
+		return fmt.Errorf("%s has no ABI version (built before gline_abi_version); rebuild gline_binding", dest)
+	}
+	if v := int(C._gl_call_abi_version(fnVersion)); v != abiVersion {
+		return fmt.Errorf("%s implements ABI version %d, but this package requires version %d; rebuild gline_binding", dest, v, abiVersion)
+	}
+
 	var e error
 	// Span
 	if fnNewSpanModel, e = loadSym("new_span_model"); e != nil {
@@ -239,14 +291,52 @@ func Init() error {
 		return e
 	}
 
-	initialized = true
+	// Errors
+	if fnLastError, e = loadSym("gline_last_error"); e != nil {
+		return e
+	}
 	return nil
 }
 
-func init() {
-	// defer Init() call to user? Or auto?
-	// Auto-init logs warning if fails as per embedeverything pattern
-	if err := Init(); err != nil {
-		// fmt.Fprintf(os.Stderr, "WARNING: go-gline-rs failed to initialize: %v\n", err)
+// unload drops whatever a failed load resolved, closes the library and removes
+// its temp dir, so the next Init starts clean.
+func unload() {
+	fnNewSpanModel, fnInferenceSpan, fnFreeSpanModel = nil, nil, nil
+	fnNewTokenModel, fnInferenceToken, fnFreeTokenModel = nil, nil, nil
+	fnFreeBatchResult = nil
+	fnNewRelationModel, fnAddRelationSchema, fnInferenceRelation, fnFreeRelationModel, fnFreeRelationResult = nil, nil, nil, nil, nil
+	fnLastError = nil
+	if dlHandle != nil {
+		C._gl_close_lib(dlHandle)
+		dlHandle = nil
+	}
+	if libDir != "" {
+		_ = os.RemoveAll(libDir)
+		libDir = ""
+	}
+}
+
+// ready reports whether Init has succeeded.
+func ready() bool {
+	initMu.Lock()
+	defer initMu.Unlock()
+	return initialized
+}
+
+// lastError returns the message recorded by the binding's last failed call on
+// this thread. Callers must pin the goroutine (runtime.LockOSThread) across
+// the failing call and this one.
+func lastError() string {
+	if c := C._gl_call_last_error(fnLastError); c != nil {
+		return C.GoString(c)
+	}
+	return ""
+}
+
+// withCause appends the binding's last error, if any, to msg.
+func withCause(msg string) error {
+	if cause := lastError(); cause != "" {
+		return fmt.Errorf("%s: %s", msg, cause)
 	}
+	return errors.New(msg)
 }
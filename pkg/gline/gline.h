@@ -33,53 +33,80 @@ typedef struct {
   size_t count;
 } BatchRelationResult;
 
+// Inference parameters; see GlineParams in gline_binding. Fields set to their
+// "keep" value (threshold < 0, flat_ner/multi_label < 0, max_width/threads 0)
+// leave the current setting alone.
+typedef struct {
+  float threshold;
+  int flat_ner;
+  int multi_label;
+  size_t max_width;
+  size_t threads;
+} GlineParams;
+
 // Function types from Rust library
-typedef void *(*new_span_model_t)(const char *, const char *);
+typedef void *(*new_span_model_t)(const char *, const char *,
+                                  const GlineParams *);
 typedef BatchResult *(*inference_span_t)(void *, const char **, size_t,
-                                         const char **, size_t);
+                                         const char **, size_t,
+                                         const GlineParams *);
 typedef void (*free_span_model_t)(void *);
 
-typedef void *(*new_token_model_t)(const char *, const char *);
+typedef void *(*new_token_model_t)(const char *, const char *,
+                                   const GlineParams *);
 typedef BatchResult *(*inference_token_t)(void *, const char **, size_t,
-                                          const char **, size_t);
+                                          const char **, size_t,
+                                          const GlineParams *);
 typedef void (*free_token_model_t)(void *);
 
 typedef void (*free_batch_result_t)(BatchResult *);
 
-typedef void *(*new_relation_model_t)(const char *, const char *);
-typedef void (*add_relation_schema_t)(void *, const char *, const char **,
-                                      size_t, const char **, size_t);
+typedef void *(*new_relation_model_t)(const char *, const char *,
+                                      const GlineParams *);
+typedef int (*add_relation_schema_t)(void *, const char *, const char **,
+                                     size_t, const char **, size_t);
 typedef BatchRelationResult *(*inference_relation_t)(void *, const char **,
                                                      size_t, const char **,
-                                                     size_t);
+                                                     size_t,
+                                                     const GlineParams *);
 typedef void (*free_relation_model_t)(void *);
 typedef void (*free_relation_result_t)(BatchRelationResult *);
+typedef const char *(*gline_last_error_t)(void);
+typedef int (*gline_abi_version_t)(void);
 
 // Function Prototypes for Wrappers (implemented in gline.go preamble or c file)
 static void *_gl_open_lib(const char *path);
 static char *_gl_get_dlerror();
 static void *_gl_get_sym(void *handle, const char *name);
 
-void *_gl_call_new_span_model(void *f, const char *m, const char *t);
+void *_gl_call_new_span_model(void *f, const char *m, const char *t,
+                              const GlineParams *p);
 BatchResult *_gl_call_inference_span(void *f, void *w, const char **i,
-                                     size_t ic, const char **l, size_t lc);
+                                     size_t ic, const char **l, size_t lc,
+                                     const GlineParams *p);
 void _gl_call_free_span_model(void *f, void *w);
 
-void *_gl_call_new_token_model(void *f, const char *m, const char *t);
+void *_gl_call_new_token_model(void *f, const char *m, const char *t,
+                               const GlineParams *p);
 BatchResult *_gl_call_inference_token(void *f, void *w, const char **i,
-                                      size_t ic, const char **l, size_t lc);
+                                      size_t ic, const char **l, size_t lc,
+                                      const GlineParams *p);
 void _gl_call_free_token_model(void *f, void *w);
 
 void _gl_call_free_batch_result(void *f, BatchResult *r);
 
-void *_gl_call_new_relation_model(void *f, const char *m, const char *t);
-void _gl_call_add_relation_schema(void *f, void *w, const char *r,
-                                  const char **ht, size_t hc, const char **tt,
-                                  size_t tc);
+void *_gl_call_new_relation_model(void *f, const char *m, const char *t,
+                                  const GlineParams *p);
+int _gl_call_add_relation_schema(void *f, void *w, const char *r,
+                                 const char **ht, size_t hc, const char **tt,
+                                 size_t tc);
 BatchRelationResult *_gl_call_inference_relation(void *f, void *w,
                                                  const char **i, size_t ic,
-                                                 const char **el, size_t elc);
+                                                 const char **el, size_t elc,
+                                                 const GlineParams *p);
 void _gl_call_free_relation_model(void *f, void *w);
 void _gl_call_free_relation_result(void *f, BatchRelationResult *r);
+const char *_gl_call_last_error(void *f);
+int _gl_call_abi_version(void *f);
 
 #endif
@@ -1,32 +1,75 @@
 package gline
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 )
 
+// initOrSkip runs Init and fails the test if it does. Setting
+// GLINE_SKIP_NATIVE=1 opts out instead: a missing native library (see
+// lib/README.md) then skips the test, for jobs that do not build it.
+func initOrSkip(tb testing.TB) {
+	tb.Helper()
+	if err := Init(); err != nil {
+		if errors.Is(err, fs.ErrNotExist) && os.Getenv("GLINE_SKIP_NATIVE") == "1" {
+			tb.Skipf("GLINE_SKIP_NATIVE=1 and native gline_binding not built: %v", err)
+		}
+		tb.Fatalf("Failed to init: %v", err)
+	}
+}
+
 // TestInit verifies that the library can be loaded.
 // It does NOT require a model file.
 func TestInit(t *testing.T) {
-	err := Init()
-	if err != nil {
-		t.Fatalf("Failed to initialize gline: %v", err)
+	initOrSkip(t)
+	if err := Init(); err != nil {
+		t.Fatalf("Second Init: %v", err)
 	}
 	fmt.Println("Successfully initialized gline")
 }
 
+// TestInitFailureCleanup verifies a failed Init removes its temp dir and
+// leaves no library or symbols behind. It runs where the binding is not built,
+// so extraction fails.
+func TestInitFailureCleanup(t *testing.T) {
+	if ready() {
+		t.Skip("gline already initialized")
+	}
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	for range 2 {
+		err := Init()
+		if err == nil {
+			t.Skip("native gline_binding is built; Init succeeded")
+		}
+		if left, _ := os.ReadDir(tmp); len(left) != 0 {
+			t.Errorf("failed Init left %v in the temp dir", left)
+		}
+		if dlHandle != nil || fnNewSpanModel != nil || fnLastError != nil || libDir != "" {
+			t.Errorf("failed Init left state behind: handle %p, new_span_model %p, last_error %p, dir %q", dlHandle, fnNewSpanModel, fnLastError, libDir)
+		}
+	}
+}
+
 // TestNewSpanModel_Fail tests that providing invalid paths correctly returns an error
 // (or at least doesn't crash the entire process).
-// Note: Since Rust prints to stderr and returns null on failure, we expect NewSpanModel to return nil.
+// The binding records why it failed, so the error should name the bad path.
 func TestNewSpanModel_Fail(t *testing.T) {
-	if err := Init(); err != nil {
-		t.Fatalf("Failed to init: %v", err)
-	}
+	initOrSkip(t)
 
 	model, err := NewSpanModel("invalid_model_path", "invalid_tokenizer_path")
 	if err == nil {
 		t.Error("Expected error for invalid paths, got nil")
+	} else if !strings.Contains(err.Error(), "invalid_model_path") {
+		t.Errorf("Expected the native cause in the error, got %q", err)
 	}
 	if model != nil {
 		t.Error("Expected nil model for invalid paths")
@@ -36,9 +79,7 @@ func TestNewSpanModel_Fail(t *testing.T) {
 
 // TestNewTokenModel_Fail tests invalid paths for TokenModel
 func TestNewTokenModel_Fail(t *testing.T) {
-	if err := Init(); err != nil {
-		t.Fatalf("Failed to init: %v", err)
-	}
+	initOrSkip(t)
 
 	model, err := NewTokenModel("invalid_model_path", "invalid_tokenizer_path")
 	if err == nil {
@@ -52,9 +93,7 @@ func TestNewTokenModel_Fail(t *testing.T) {
 
 // TestRelationModel_Fail tests relation model with invalid paths.
 func TestRelationModel_Fail(t *testing.T) {
-	if err := Init(); err != nil {
-		t.Fatalf("Failed to init: %v", err)
-	}
+	initOrSkip(t)
 
 	model, err := NewRelationModel("invalid_model_path", "invalid_tokenizer_path")
 	if err == nil {
@@ -67,9 +106,7 @@ func TestRelationModel_Fail(t *testing.T) {
 }
 
 func TestDownloadAndLoad(t *testing.T) {
-	if err := Init(); err != nil {
-		t.Fatalf("Failed to init: %v", err)
-	}
+	initOrSkip(t)
 
 	// Use a small model for testing
 	// This one is 250MB, might be too big for CI/regular test use?
@@ -144,9 +181,7 @@ func TestDownloadAndLoad(t *testing.T) {
 }
 
 func TestNewSpanModelFromHF(t *testing.T) {
-	if err := Init(); err != nil {
-		t.Fatalf("Failed to init: %v", err)
-	}
+	initOrSkip(t)
 
 	modelID := "onnx-community/gliner_small-v2.1"
 	model, err := NewSpanModelFromHF(modelID)
@@ -161,9 +196,7 @@ func TestNewSpanModelFromHF(t *testing.T) {
 }
 
 func TestIncompatibleModel(t *testing.T) {
-	if err := Init(); err != nil {
-		t.Fatalf("Failed to init: %v", err)
-	}
+	initOrSkip(t)
 
 	// Use a model that definitely does not have model.onnx
 	modelID := "google/flan-t5-small" // usually safetensors/bin only unless exported
@@ -181,3 +214,250 @@ func TestIncompatibleModel(t *testing.T) {
 		fmt.Printf("Got expected error: %v\n", err)
 	}
 }
+
+// BenchmarkRelationPredict measures Predict on one model, reusing the token
+// pipeline built at load time. Set GLINE_RELATION_MODEL to a Hugging Face
+// relation model ID to run it, e.g.
+//
+//	GLINE_RELATION_MODEL=onnx-community/gliner-multitask-large-v0.5 go test -bench RelationPredict -run '^$' ./pkg/gline/
+func BenchmarkRelationPredict(b *testing.B) {
+	modelID := os.Getenv("GLINE_RELATION_MODEL")
+	if modelID == "" {
+		b.Skip("GLINE_RELATION_MODEL not set")
+	}
+	initOrSkip(b)
+	model, err := NewRelationModelFromHF(modelID)
+	if err != nil {
+		b.Fatalf("Failed to load relation model: %v", err)
+	}
+	defer model.Close()
+	if err := model.AddRelationSchema("founded", []string{"person"}, []string{"organization"}); err != nil {
+		b.Fatalf("AddRelationSchema: %v", err)
+	}
+	texts := []string{"Google was founded by Larry Page."}
+	labels := []string{"person", "organization"}
+
+	for b.Loop() {
+		if _, err := model.Predict(texts, labels); err != nil {
+			b.Fatalf("Predict: %v", err)
+		}
+	}
+}
+
+// TestParams checks how Params fold and map onto the native struct; it does
+// not need the library.
+func TestParams(t *testing.T) {
+	if cParams(nil) != nil {
+		t.Error("Expected nil native params without Params")
+	}
+
+	yes, no := true, false
+	low, high, zero := float32(0.3), float32(0.7), float32(0)
+	four, eight := 4, 8
+	p := mergeParams([]Params{
+		{Threshold: &low, FlatNER: &yes, Threads: &four},
+		{Threshold: &high, FlatNER: &no, MaxWidth: &eight},
+	})
+	if *p.Threshold != 0.7 || *p.FlatNER || *p.MaxWidth != 8 || *p.Threads != 4 || p.MultiLabel != nil {
+		t.Errorf("Unexpected merge result: %+v", p)
+	}
+
+	c := cParams([]Params{{MultiLabel: &yes}})
+	if float32(c.threshold) >= 0 || int(c.flat_ner) != -1 || int(c.multi_label) != 1 || c.max_width != 0 || c.threads != 0 {
+		t.Errorf("Unexpected native params: %+v", *c)
+	}
+	// A zero threshold is sent as is, not taken as "keep".
+	if c := cParams([]Params{{Threshold: &low}, {Threshold: &zero}}); float32(c.threshold) != 0 {
+		t.Errorf("Threshold 0 sent as %v, want 0", c.threshold)
+	}
+}
+
+// TestPredictValidation covers the argument checks, which run before the
+// native library is touched: a zero Model stands in for a closed one.
+func TestPredictValidation(t *testing.T) {
+	high, zeroWidth, negThreads := float32(1.5), 0, -2
+	tooHigh := []Params{{Threshold: &high}}
+	tests := []struct {
+		name   string
+		texts  []string
+		labels []string
+		params []Params
+		want   string
+	}{
+		{"no labels", []string{"a"}, nil, nil, "at least one label is required"},
+		{"empty label", []string{"a"}, []string{"person", ""}, nil, "label 1 is empty"},
+		{"NUL in label", []string{"a"}, []string{"per\x00son"}, nil, "label 0 contains a NUL byte"},
+		{"NUL in text", []string{"a", "b\x00"}, []string{"person"}, nil, "text 1 contains a NUL byte"},
+		{"threshold", []string{"a"}, []string{"person"}, tooHigh, "threshold 1.5 is outside [0, 1]"},
+		{"max width", []string{"a"}, []string{"person"}, []Params{{MaxWidth: &zeroWidth}}, "max width 0 is not positive"},
+		{"threads", []string{"a"}, []string{"person"}, []Params{{Threads: &negThreads}}, "threads -2 is negative"},
+		{"valid input on closed model", []string{"a"}, []string{"person"}, nil, "model is closed"},
+		{"no texts on closed model", nil, []string{"person"}, nil, "model is closed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&Model{}).Predict(tt.texts, tt.labels, tt.params...)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Model.Predict error = %v, want %q", err, tt.want)
+			}
+			want := strings.Replace(tt.want, "label", "entity label", 1)
+			_, err = (&RelationModel{}).Predict(tt.texts, tt.labels, tt.params...)
+			if err == nil || err.Error() != want {
+				t.Errorf("RelationModel.Predict error = %v, want %q", err, want)
+			}
+		})
+	}
+}
+
+// TestModelClosed runs Predict alongside Close (under -race, this checks they
+// share the Model's lock) and expects every call to see the model closed.
+func TestModelClosed(t *testing.T) {
+	m := &Model{}
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			m.Close()
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := m.Predict([]string{"a"}, []string{"person"}); err == nil || err.Error() != "model is closed" {
+				t.Errorf("Predict on a closed model: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestAddRelationSchemaValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		relation   string
+		head, tail []string
+		want       string
+	}{
+		{"empty relation", "", []string{"person"}, []string{"org"}, "relation name is empty"},
+		{"no head types", "founded", nil, []string{"org"}, "head/tail types cannot be empty"},
+		{"no tail types", "founded", []string{"person"}, nil, "head/tail types cannot be empty"},
+		{"empty head type", "founded", []string{""}, []string{"org"}, "head type 0 is empty"},
+		{"empty tail type", "founded", []string{"person"}, []string{"org", ""}, "tail type 1 is empty"},
+		{"valid schema on closed model", "founded", []string{"person"}, []string{"org"}, "model is closed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&RelationModel{}).AddRelationSchema(tt.relation, tt.head, tt.tail)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("AddRelationSchema error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestDownloadOffline resolves files from a hand-built cache in the Hugging
+// Face layout, so it needs neither the network nor the native library.
+func TestDownloadOffline(t *testing.T) {
+	cacheDir := t.TempDir()
+	repoDir := filepath.Join(cacheDir, "models--org--gliner")
+	snapshot := filepath.Join(repoDir, "snapshots", "abc123")
+	for name, data := range map[string]string{
+		filepath.Join(repoDir, "info", "v1"):                    `{"sha": "abc123"}`,
+		filepath.Join(snapshot, "onnx", "model_quantized.onnx"): "weights",
+		filepath.Join(snapshot, "tokenizer.json"):               "{}",
+	} {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum := sha256.Sum256([]byte("weights"))
+
+	opts := DownloadOptions{CacheDir: cacheDir, Revision: "v1", ModelFile: "model_quantized.onnx", SHA256: hex.EncodeToString(sum[:]), Offline: true}
+	modelPath, tokenizerPath, err := DownloadModelWithOptions("org/gliner", opts)
+	if err != nil {
+		t.Fatalf("DownloadModelWithOptions: %v", err)
+	}
+	if want := filepath.Join(snapshot, "onnx", "model_quantized.onnx"); modelPath != want {
+		t.Errorf("modelPath = %s, want %s", modelPath, want)
+	}
+	if want := filepath.Join(snapshot, "tokenizer.json"); tokenizerPath != want {
+		t.Errorf("tokenizerPath = %s, want %s", tokenizerPath, want)
+	}
+
+	// A commit hash resolves without an info file.
+	byHash := opts
+	byHash.Revision = "abc123"
+	if _, _, err := DownloadModelWithOptions("org/gliner", byHash); err != nil {
+		t.Errorf("by commit hash: %v", err)
+	}
+
+	for name, mutate := range map[string]func(*DownloadOptions){
+		"checksum mismatch": func(o *DownloadOptions) { o.SHA256 = strings.Repeat("0", 64) },
+		"unknown revision":  func(o *DownloadOptions) { o.Revision = "v2" },
+		"missing file":      func(o *DownloadOptions) { o.ModelFile = "model_fp16.onnx" },
+	} {
+		o := opts
+		mutate(&o)
+		if _, _, err := DownloadModelWithOptions("org/gliner", o); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+
+	// A revision is never joined into a path outside the cache.
+	for _, rev := range []string{"..", "../../etc", `..\..\etc`} {
+		o := opts
+		o.Revision = rev
+		if _, _, err := DownloadModelWithOptions("org/gliner", o); err == nil || !strings.Contains(err.Error(), "invalid revision") {
+			t.Errorf("revision %q: got %v, want an invalid revision error", rev, err)
+		}
+	}
+
+	// Without a ModelFile, a cache missing model.onnx reports the real cause
+	// rather than calling the model incompatible.
+	noFile := opts
+	noFile.ModelFile, noFile.SHA256 = "", ""
+	if _, _, err := DownloadModelWithOptions("org/gliner", noFile); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing default model file: got %v, want an error wrapping fs.ErrNotExist", err)
+	}
+	// Offline, a checksum mismatch leaves the cached file in place.
+	if _, err := os.Stat(modelPath); err != nil {
+		t.Errorf("offline checksum mismatch removed the cached file: %v", err)
+	}
+}
+
+// TestUnlinkSnapshot verifies a bad model file is unlinked from its snapshot
+// while the blob behind it, and any regular file, stay in place.
+func TestUnlinkSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	blob := filepath.Join(dir, "blobs", "etag")
+	link := filepath.Join(dir, "snapshots", "abc123", "model.onnx")
+	plain := filepath.Join(dir, "snapshots", "abc123", "tokenizer.json")
+	for _, d := range []string{filepath.Dir(blob), filepath.Dir(link)} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []string{blob, plain} {
+		if err := os.WriteFile(p, []byte("bad weights"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join("..", "..", "blobs", "etag"), link); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{link, plain} {
+		if err := unlinkSnapshot(p); err != nil {
+			t.Fatalf("unlinkSnapshot(%s): %v", p, err)
+		}
+	}
+	if _, err := os.Lstat(link); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("snapshot link still exists (%v)", err)
+	}
+	for _, p := range []string{blob, plain} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s was removed: %v", p, err)
+		}
+	}
+}
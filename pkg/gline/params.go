@@ -0,0 +1,82 @@
+package gline
+
+/*
+#include "gline.h"
+*/
+import "C"
+
+// Params sets gline-rs inference parameters. Nil fields keep the current
+// value: the gline-rs default when passed to a constructor, the model's
+// load-time value when passed to Predict. A set field always applies, zero
+// included: a Threshold of 0 keeps every span.
+type Params struct {
+	// Threshold is the minimum span probability, in [0, 1] (default 0.5).
+	Threshold *float32
+	// FlatNER disallows overlapping spans (default true).
+	FlatNER *bool
+	// MultiLabel allows one span to carry several labels (default false).
+	MultiLabel *bool
+	// MaxWidth is the maximum span width in tokens, at least 1 (default 12).
+	MaxWidth *int
+	// Threads is the number of ONNX Runtime threads, 0 for its default. It is
+	// only read by the constructors.
+	Threads *int
+}
+
+// mergeParams folds ps left to right, later non-nil fields winning.
+func mergeParams(ps []Params) Params {
+	var out Params
+	for _, p := range ps {
+		if p.Threshold != nil {
+			out.Threshold = p.Threshold
+		}
+		if p.FlatNER != nil {
+			out.FlatNER = p.FlatNER
+		}
+		if p.MultiLabel != nil {
+			out.MultiLabel = p.MultiLabel
+		}
+		if p.MaxWidth != nil {
+			out.MaxWidth = p.MaxWidth
+		}
+		if p.Threads != nil {
+			out.Threads = p.Threads
+		}
+	}
+	return out
+}
+
+// cParams converts ps to the native struct, or nil when ps is empty so the
+// binding keeps every setting.
+func cParams(ps []Params) *C.GlineParams {
+	if len(ps) == 0 {
+		return nil
+	}
+	p := mergeParams(ps)
+	c := &C.GlineParams{
+		threshold:   -1,
+		flat_ner:    triState(p.FlatNER),
+		multi_label: triState(p.MultiLabel),
+	}
+	if p.Threshold != nil {
+		c.threshold = C.float(*p.Threshold)
+	}
+	if p.MaxWidth != nil {
+		c.max_width = C.size_t(*p.MaxWidth)
+	}
+	if p.Threads != nil {
+		c.threads = C.size_t(*p.Threads)
+	}
+	return c
+}
+
+func triState(b *bool) C.int {
+	switch {
+	case b == nil:
+		return -1
+	case *b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -2,6 +2,8 @@ package gline
 
 import (
 	"errors"
+	"runtime"
+	"sync"
 	"unsafe"
 )
 
@@ -18,14 +20,21 @@ type Relation struct {
 	Probability   float32 `json:"probability"`
 }
 
+// RelationModel extracts relations. The native side reuses its token
+// pipeline between calls, so calls on one RelationModel are serialized.
 type RelationModel struct {
+	mu  sync.Mutex
 	ptr unsafe.Pointer
 }
 
-// NewRelationModel
-func NewRelationModel(modelPath, tokenizerPath string) (*RelationModel, error) {
-	if !initialized {
-		return nil, errors.New("library not initialized")
+// NewRelationModel loads a relation model. params, if given, set its default
+// inference parameters.
+func NewRelationModel(modelPath, tokenizerPath string, params ...Params) (*RelationModel, error) {
+	if !ready() {
+		return nil, errNotInitialized
+	}
+	if err := validateParams(params); err != nil {
+		return nil, err
 	}
 
 	cMod := C.CString(modelPath)
@@ -33,23 +42,28 @@ func NewRelationModel(modelPath, tokenizerPath string) (*RelationModel, error) {
 	defer C.free(unsafe.Pointer(cMod))
 	defer C.free(unsafe.Pointer(cTok))
 
-	ptr := C._gl_call_new_relation_model(fnNewRelationModel, cMod, cTok)
+	// The last error is thread-local on the Rust side; read it on the same thread.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	ptr := C._gl_call_new_relation_model(fnNewRelationModel, cMod, cTok, cParams(params))
 	if ptr == nil {
-		return nil, errors.New("failed to create relation model")
+		return nil, withCause("failed to create relation model")
 	}
 	return &RelationModel{ptr: ptr}, nil
 }
 
 // NewRelationModelFromHF loads a Relation model directly from Hugging Face
-func NewRelationModelFromHF(modelID string) (*RelationModel, error) {
+func NewRelationModelFromHF(modelID string, params ...Params) (*RelationModel, error) {
 	modelPath, tokenizerPath, err := DownloadModel(modelID, "")
 	if err != nil {
 		return nil, err
 	}
-	return NewRelationModel(modelPath, tokenizerPath)
+	return NewRelationModel(modelPath, tokenizerPath, params...)
 }
 
 func (r *RelationModel) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.ptr != nil {
 		C._gl_call_free_relation_model(fnFreeRelationModel, r.ptr)
 		r.ptr = nil
@@ -58,12 +72,14 @@ func (r *RelationModel) Close() {
 
 // AddRelationSchema adds a relation definition to the schema
 func (r *RelationModel) AddRelationSchema(relation string, headTypes []string, tailTypes []string) error {
+	if err := validateSchema(relation, headTypes, tailTypes); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.ptr == nil {
 		return errors.New("model is closed")
 	}
-	if len(headTypes) == 0 || len(tailTypes) == 0 {
-		return errors.New("head/tail types cannot be empty")
-	}
 
 	cRel := C.CString(relation)
 	defer C.free(unsafe.Pointer(cRel))
@@ -82,18 +98,31 @@ func (r *RelationModel) AddRelationSchema(relation string, headTypes []string, t
 		cTails[i] = cStr
 	}
 
-	C._gl_call_add_relation_schema(fnAddRelationSchema, r.ptr, cRel,
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if C._gl_call_add_relation_schema(fnAddRelationSchema, r.ptr, cRel,
 		(**C.char)(unsafe.Pointer(&cHeads[0])), C.size_t(len(headTypes)),
-		(**C.char)(unsafe.Pointer(&cTails[0])), C.size_t(len(tailTypes)))
-
+		(**C.char)(unsafe.Pointer(&cTails[0])), C.size_t(len(tailTypes))) != 0 {
+		return withCause("failed to add relation schema")
+	}
 	return nil
 }
 
 // Predict extracts relations. Note: It requires entity labels because it runs NER internally.
-func (r *RelationModel) Predict(texts []string, entityLabels []string) ([][]Relation, error) {
+// params, if given, override the model's inference parameters for this call.
+// It returns an empty result, without running the model, when texts is empty.
+func (r *RelationModel) Predict(texts []string, entityLabels []string, params ...Params) ([][]Relation, error) {
+	if err := validateInputs(texts, entityLabels, "entity label", params); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.ptr == nil {
 		return nil, errors.New("model is closed")
 	}
+	if len(texts) == 0 {
+		return [][]Relation{}, nil
+	}
 
 	cTexts := make([]*C.char, len(texts))
 	for i, s := range texts {
@@ -109,12 +138,15 @@ func (r *RelationModel) Predict(texts []string, entityLabels []string) ([][]Rela
 		cLabels[i] = cStr
 	}
 
+	cp := cParams(params)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
 	res := C._gl_call_inference_relation(fnInferenceRelation, r.ptr,
 		(**C.char)(unsafe.Pointer(&cTexts[0])), C.size_t(len(texts)),
-		(**C.char)(unsafe.Pointer(&cLabels[0])), C.size_t(len(entityLabels)))
+		(**C.char)(unsafe.Pointer(&cLabels[0])), C.size_t(len(entityLabels)), cp)
 
 	if res == nil {
-		return nil, errors.New("relation inference failed")
+		return nil, withCause("relation inference failed")
 	}
 	defer C._gl_call_free_relation_result(fnFreeRelationResult, res)
 
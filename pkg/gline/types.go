@@ -2,6 +2,8 @@ package gline
 
 import (
 	"errors"
+	"runtime"
+	"sync"
 	"unsafe"
 )
 
@@ -20,17 +22,24 @@ type Entity struct {
 	Probability float32 `json:"probability"`
 }
 
+// Model is an entity model in span or token mode. Calls on one Model are
+// serialized, and Close waits for a running Predict before freeing the model.
 type Model struct {
+	mu      sync.Mutex
 	ptr     unsafe.Pointer
 	isToken bool
 }
 
-// NewSpanModel loads a model in Span Mode
+// NewSpanModel loads a model in Span Mode. params, if given, set its default
+// inference parameters.
 //
 // Deprecated: use package gliner2 (gliner2.NewFromHuggingFace) instead.
-func NewSpanModel(modelPath, tokenizerPath string) (*Model, error) {
-	if !initialized {
-		return nil, errors.New("library not initialized")
+func NewSpanModel(modelPath, tokenizerPath string, params ...Params) (*Model, error) {
+	if !ready() {
+		return nil, errNotInitialized
+	}
+	if err := validateParams(params); err != nil {
+		return nil, err
 	}
 
 	cMod := C.CString(modelPath)
@@ -38,19 +47,25 @@ func NewSpanModel(modelPath, tokenizerPath string) (*Model, error) {
 	defer C.free(unsafe.Pointer(cMod))
 	defer C.free(unsafe.Pointer(cTok))
 
-	ptr := C._gl_call_new_span_model(fnNewSpanModel, cMod, cTok)
+	// The last error is thread-local on the Rust side; read it on the same thread.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	ptr := C._gl_call_new_span_model(fnNewSpanModel, cMod, cTok, cParams(params))
 	if ptr == nil {
-		return nil, errors.New("failed to create span model")
+		return nil, withCause("failed to create span model")
 	}
 	return &Model{ptr: ptr, isToken: false}, nil
 }
 
-// NewTokenModel loads a model in Token Mode
+// NewTokenModel loads a model in Token Mode; see NewSpanModel for params.
 //
 // Deprecated: use package gliner2 (gliner2.NewFromHuggingFace) instead.
-func NewTokenModel(modelPath, tokenizerPath string) (*Model, error) {
-	if !initialized {
-		return nil, errors.New("library not initialized")
+func NewTokenModel(modelPath, tokenizerPath string, params ...Params) (*Model, error) {
+	if !ready() {
+		return nil, errNotInitialized
+	}
+	if err := validateParams(params); err != nil {
+		return nil, err
 	}
 
 	cMod := C.CString(modelPath)
@@ -58,9 +73,11 @@ func NewTokenModel(modelPath, tokenizerPath string) (*Model, error) {
 	defer C.free(unsafe.Pointer(cMod))
 	defer C.free(unsafe.Pointer(cTok))
 
-	ptr := C._gl_call_new_token_model(fnNewTokenModel, cMod, cTok)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	ptr := C._gl_call_new_token_model(fnNewTokenModel, cMod, cTok, cParams(params))
 	if ptr == nil {
-		return nil, errors.New("failed to create token model")
+		return nil, withCause("failed to create token model")
 	}
 	return &Model{ptr: ptr, isToken: true}, nil
 }
@@ -68,26 +85,28 @@ func NewTokenModel(modelPath, tokenizerPath string) (*Model, error) {
 // NewSpanModelFromHF loads a Span model directly from Hugging Face
 //
 // Deprecated: use gliner2.NewFromHuggingFace (package gliner2) instead.
-func NewSpanModelFromHF(modelID string) (*Model, error) {
+func NewSpanModelFromHF(modelID string, params ...Params) (*Model, error) {
 	modelPath, tokenizerPath, err := DownloadModel(modelID, "")
 	if err != nil {
 		return nil, err
 	}
-	return NewSpanModel(modelPath, tokenizerPath)
+	return NewSpanModel(modelPath, tokenizerPath, params...)
 }
 
 // NewTokenModelFromHF loads a Token model directly from Hugging Face
 //
 // Deprecated: use gliner2.NewFromHuggingFace (package gliner2) instead.
-func NewTokenModelFromHF(modelID string) (*Model, error) {
+func NewTokenModelFromHF(modelID string, params ...Params) (*Model, error) {
 	modelPath, tokenizerPath, err := DownloadModel(modelID, "")
 	if err != nil {
 		return nil, err
 	}
-	return NewTokenModel(modelPath, tokenizerPath)
+	return NewTokenModel(modelPath, tokenizerPath, params...)
 }
 
 func (m *Model) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.ptr != nil {
 		if m.isToken {
 			C._gl_call_free_token_model(fnFreeTokenModel, m.ptr)
@@ -98,10 +117,21 @@ func (m *Model) Close() {
 	}
 }
 
-func (m *Model) Predict(texts []string, labels []string) ([][]Entity, error) {
+// Predict extracts entities of the given labels from each text. params, if
+// given, override the model's inference parameters for this call. It returns
+// an empty result, without running the model, when texts is empty.
+func (m *Model) Predict(texts []string, labels []string, params ...Params) ([][]Entity, error) {
+	if err := validateInputs(texts, labels, "label", params); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.ptr == nil {
 		return nil, errors.New("model is closed")
 	}
+	if len(texts) == 0 {
+		return [][]Entity{}, nil
+	}
 
 	// Prepare Inputs
 	cTexts := make([]*C.char, len(texts))
@@ -118,19 +148,22 @@ func (m *Model) Predict(texts []string, labels []string) ([][]Entity, error) {
 		cLabels[i] = cStr
 	}
 
+	cp := cParams(params)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
 	var res *C.BatchResult
 	if m.isToken {
 		res = C._gl_call_inference_token(fnInferenceToken, m.ptr,
 			(**C.char)(unsafe.Pointer(&cTexts[0])), C.size_t(len(texts)),
-			(**C.char)(unsafe.Pointer(&cLabels[0])), C.size_t(len(labels)))
+			(**C.char)(unsafe.Pointer(&cLabels[0])), C.size_t(len(labels)), cp)
 	} else {
 		res = C._gl_call_inference_span(fnInferenceSpan, m.ptr,
 			(**C.char)(unsafe.Pointer(&cTexts[0])), C.size_t(len(texts)),
-			(**C.char)(unsafe.Pointer(&cLabels[0])), C.size_t(len(labels)))
+			(**C.char)(unsafe.Pointer(&cLabels[0])), C.size_t(len(labels)), cp)
 	}
 
 	if res == nil {
-		return nil, errors.New("inference failed")
+		return nil, withCause("inference failed")
 	}
 	defer C._gl_call_free_batch_result(fnFreeBatchResult, res)
 
@@ -0,0 +1,68 @@
+package gline
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// validateInputs checks Predict arguments before they are copied into C
+// strings. labelKind names the labels in errors ("label", "entity label").
+func validateInputs(texts, labels []string, labelKind string, params []Params) error {
+	for i, t := range texts {
+		if strings.IndexByte(t, 0) >= 0 {
+			return fmt.Errorf("text %d contains a NUL byte", i)
+		}
+	}
+	if len(labels) == 0 {
+		return fmt.Errorf("at least one %s is required", labelKind)
+	}
+	if err := validateStrings(labels, labelKind); err != nil {
+		return err
+	}
+	return validateParams(params)
+}
+
+// validateStrings rejects empty strings and strings with NUL bytes, which C
+// would silently truncate.
+func validateStrings(ss []string, kind string) error {
+	for i, s := range ss {
+		switch {
+		case s == "":
+			return fmt.Errorf("%s %d is empty", kind, i)
+		case strings.IndexByte(s, 0) >= 0:
+			return fmt.Errorf("%s %d contains a NUL byte", kind, i)
+		}
+	}
+	return nil
+}
+
+func validateParams(params []Params) error {
+	for _, p := range params {
+		switch {
+		case p.Threshold != nil && !(*p.Threshold >= 0 && *p.Threshold <= 1):
+			return fmt.Errorf("threshold %v is outside [0, 1]", *p.Threshold)
+		case p.MaxWidth != nil && *p.MaxWidth < 1:
+			return fmt.Errorf("max width %d is not positive", *p.MaxWidth)
+		case p.Threads != nil && *p.Threads < 0:
+			return fmt.Errorf("threads %d is negative", *p.Threads)
+		}
+	}
+	return nil
+}
+
+func validateSchema(relation string, headTypes, tailTypes []string) error {
+	if relation == "" {
+		return errors.New("relation name is empty")
+	}
+	if strings.IndexByte(relation, 0) >= 0 {
+		return errors.New("relation name contains a NUL byte")
+	}
+	if len(headTypes) == 0 || len(tailTypes) == 0 {
+		return errors.New("head/tail types cannot be empty")
+	}
+	if err := validateStrings(headTypes, "head type"); err != nil {
+		return err
+	}
+	return validateStrings(tailTypes, "tail type")
+}
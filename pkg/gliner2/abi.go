@@ -0,0 +1,123 @@
+package gliner2
+
+/*
+#include "gliner2.h"
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"unsafe"
+)
+
+// ABIVersion is the gliner2_binding C ABI version this package is written
+// against. Init refuses to load a library that reports any other version, or
+// none at all, with one exception: the embedded artifacts listed in
+// legacyArtifacts, which predate the handshake and are loaded with a warning
+// until the bundle-libs workflow rebuilds them.
+const ABIVersion = 1
+
+// Capabilities reported by the native library. Task kinds use the Task.Type
+// discriminator, so a schema can be checked task by task.
+const (
+	CapEntities        = "entities"
+	CapRelations       = "relations"
+	CapClassifications = "classifications"
+	CapStructure       = "structure"
+	CapFlatNER         = "flat_ner"
+)
+
+var capabilities []string // set by Init from gliner2_capabilities
+
+// legacyArtifacts holds the SHA-256 of each embedded lib/*.gz artifact built
+// before the ABI handshake. Their gliner2_new / gliner2_extract signatures are
+// those of ABI version 1 and they support legacyCapabilities. Remove entries
+// as the artifacts are rebuilt; a library loaded from LibPathEnv or
+// Config.LibPath never qualifies.
+var legacyArtifacts = []string{
+	"06c7b92d2d5e689aa9421febf3024d9926eb76844c5cc9cb03650988fbd2be44", // linux-amd64
+	"8a235c98a064e6f065c4fd6b4b4dd848332694a33897075b31b9abd849aba7f7", // linux-arm64
+}
+
+var legacyCapabilities = []string{CapEntities, CapRelations, CapClassifications, CapStructure, CapFlatNER}
+
+// isLegacyArtifact reports whether the embedded artifact at embedPath is one of
+// legacyArtifacts.
+func isLegacyArtifact(embedPath string) bool {
+	sum, err := fsSHA256(libFS, embedPath)
+	return err == nil && slices.Contains(legacyArtifacts, sum)
+}
+
+// checkABI resolves the handshake symbols from the library at path, verifies its
+// ABI version and records its capability list. It returns the version found.
+// A library without the handshake is refused unless legacy is set (see
+// legacyArtifacts); it then reports version 0 with legacyCapabilities.
+func checkABI(path string, legacy bool, loadSym func(string) (unsafe.Pointer, error)) (int, error) {
+	fnVersion, err := loadSym("gliner2_abi_version")
+	if err != nil {
+		if !legacy {
+			return 0, fmt.Errorf("%s has no ABI version (built before the ABI handshake); rebuild it from the matching gliner2_binding sources with `make gliner2`", path)
+		}
+		log.Printf("gliner2: embedded %s predates the ABI handshake; assuming capabilities %v until it is rebuilt", path, legacyCapabilities)
+		capabilities = slices.Clone(legacyCapabilities)
+		return 0, nil
+	}
+	if v := int(C._g2_call_abi_version(fnVersion)); v != ABIVersion {
+		return v, fmt.Errorf("%s implements ABI version %d, but this package requires version %d; rebuild the library from the matching gliner2_binding sources", path, v, ABIVersion)
+	}
+
+	fnCaps, err := loadSym("gliner2_capabilities")
+	if err != nil {
+		return ABIVersion, err
+	}
+	caps, err := parseCapabilities(C._g2_call_capabilities(fnCaps))
+	if err != nil {
+		return ABIVersion, fmt.Errorf("%s: %w", path, err)
+	}
+	capabilities = caps
+	return ABIVersion, nil
+}
+
+func parseCapabilities(c *C.char) ([]string, error) {
+	if c == nil {
+		return nil, nil
+	}
+	var caps []string
+	if err := json.Unmarshal([]byte(C.GoString(c)), &caps); err != nil {
+		return nil, fmt.Errorf("decode capabilities: %w", err)
+	}
+	return caps, nil
+}
+
+// Capabilities returns the optional features reported by the loaded native
+// library (see the Cap* constants). It initializes the library if needed and
+// returns nil when that fails.
+func Capabilities() []string {
+	if Init() != nil {
+		return nil
+	}
+	return slices.Clone(capabilities)
+}
+
+// HasCapability reports whether the loaded native library supports name.
+func HasCapability(name string) bool {
+	return Init() == nil && slices.Contains(capabilities, name)
+}
+
+// checkTasks rejects tasks (and options) the loaded library does not support,
+// so an older build fails with a clear error instead of a JSON decode failure
+// deep inside the engine.
+func checkTasks(tasks []Task, flatNER bool) error {
+	for _, t := range tasks {
+		if !slices.Contains(capabilities, t.Type) {
+			return fmt.Errorf("%w: %q tasks", ErrUnsupported, t.Type)
+		}
+	}
+	if flatNER && !slices.Contains(capabilities, CapFlatNER) {
+		return fmt.Errorf("%w: flat NER", ErrUnsupported)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package gliner2
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gofrs/flock"
+)
+
+// CacheDirEnv names the environment variable that overrides where the embedded
+// native libraries are extracted. When unset, the user cache directory is used
+// (e.g. ~/.cache/go-gliner2 on Linux, ~/Library/Caches/go-gliner2 on macOS).
+const CacheDirEnv = "GLINER2_CACHE_DIR"
+
+var (
+	tempMu  sync.Mutex
+	tempDir string // fallback extraction dir owned by this process; see Shutdown
+)
+
+// defaultCacheDir returns the persistent root for extracted native libraries, or
+// "" when none is available (no $GLINER2_CACHE_DIR and no user cache dir).
+func defaultCacheDir() string {
+	if d := os.Getenv(CacheDirEnv); d != "" {
+		return d
+	}
+	if d, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(d, "go-gliner2")
+	}
+	return ""
+}
+
+// extractEmbedded extracts an embedded artifact into the persistent cache under
+// root (defaultCacheDir when empty) and returns its on-disk path. If the cache
+// cannot be used (unset, not writable, ...) it falls back to a per-process temp
+// dir that Shutdown removes.
+func extractEmbedded(srcPath, diskName, root string) (string, error) {
+	if _, err := fs.Stat(libFS, srcPath); err != nil {
+		return "", fmt.Errorf("open embedded %s: %w", srcPath, err)
+	}
+	if root == "" {
+		root = defaultCacheDir()
+	}
+	if root != "" {
+		if dest, err := extractCached(libFS, srcPath, root, diskName); err == nil {
+			return dest, nil
+		}
+	}
+	tmp, err := processTempDir()
+	if err != nil {
+		return "", err
+	}
+	return extractCached(libFS, srcPath, tmp, diskName)
+}
+
+// processTempDir lazily creates the fallback extraction dir for this process.
+func processTempDir() (string, error) {
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	if tempDir != "" {
+		return tempDir, nil
+	}
+	d, err := os.MkdirTemp("", "go-gliner2-lib")
+	if err != nil {
+		return "", fmt.Errorf("temp dir: %w", err)
+	}
+	tempDir = d
+	return d, nil
+}
+
+// extractCached extracts srcPath (gunzipping *.gz) from fsys to
+// <root>/<sha256 of the embedded bytes>/diskName and returns that path.
+//
+// A previous extraction is reused when the file's SHA-256 still matches the one
+// recorded next to it (diskName + ".sha256"); otherwise the file is rewritten via
+// a temp file + rename. The whole check-and-write runs under an exclusive lock on
+// <dir>/.lock, so concurrent processes sharing root never observe (or dlopen) a
+// partially written library.
+func extractCached(fsys fs.FS, srcPath, root, diskName string) (string, error) {
+	key, err := fsSHA256(fsys, srcPath)
+	if err != nil {
+		return "", fmt.Errorf("hash embedded %s: %w", srcPath, err)
+	}
+	dir := filepath.Join(root, key)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create cache dir %s: %w", dir, err)
+	}
+
+	lock := flock.New(filepath.Join(dir, ".lock"))
+	if err := lock.Lock(); err != nil {
+		return "", fmt.Errorf("lock %s: %w", dir, err)
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	dest := filepath.Join(dir, diskName)
+	sumPath := dest + ".sha256"
+	if want, err := os.ReadFile(sumPath); err == nil {
+		if got, err := fileSHA256(dest); err == nil && got == strings.TrimSpace(string(want)) {
+			return dest, nil
+		}
+	}
+
+	// Leftovers from an extraction that crashed before its rename.
+	stale, _ := filepath.Glob(filepath.Join(dir, diskName+".tmp-*"))
+	for _, p := range stale {
+		_ = os.Remove(p)
+	}
+
+	tmp, err := os.CreateTemp(dir, diskName+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("create temp in %s: %w", dir, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }() // no-op after a successful rename
+
+	h := sha256.New()
+	if err := decompress(fsys, srcPath, io.MultiWriter(tmp, h)); err != nil {
+		_ = tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("write %s: %w", tmp.Name(), err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return "", fmt.Errorf("chmod %s: %w", tmp.Name(), err)
+	}
+	// Renaming over an existing (stale) copy is safe even while another process
+	// has it dlopen'd: that process keeps the old inode.
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return "", fmt.Errorf("install %s: %w", dest, err)
+	}
+	if err := os.WriteFile(sumPath, []byte(hex.EncodeToString(h.Sum(nil))+"\n"), 0o644); err != nil {
+		return "", fmt.Errorf("write %s: %w", sumPath, err)
+	}
+	return dest, nil
+}
+
+// decompress copies the embedded file srcPath to w, gunzipping *.gz files.
+func decompress(fsys fs.FS, srcPath string, w io.Writer) error {
+	f, err := fsys.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("open embedded %s: %w", srcPath, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var r io.Reader = f
+	if strings.HasSuffix(srcPath, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("gzip reader %s: %w", srcPath, err)
+		}
+		defer func() { _ = gz.Close() }()
+		r = gz
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("copy %s: %w", srcPath, err)
+	}
+	return nil
+}
+
+func fsSHA256(fsys fs.FS, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	return readerSHA256(f)
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	return readerSHA256(f)
+}
+
+func readerSHA256(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Shutdown removes the temporary extraction directory, if this process had to
+// create one because the persistent cache was unavailable. Copies in the
+// persistent cache are kept for reuse by later processes. The native library
+// stays loaded, so close all engines first; Shutdown is meant for process exit.
+func Shutdown() error {
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	if tempDir == "" {
+		return nil
+	}
+	dir := tempDir
+	tempDir = ""
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("gliner2: remove %s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+// Package client is a pure-Go gliner2.Extractor that calls a remote
+// gliner2-server over HTTP, for services that cannot link cgo. It speaks the
+// server's native POST /v1/extract API, which takes []gliner2.Task as is and
+// returns each text's full gliner2.Result, so a Client returns the same
+// Results as a local *gliner2.Engine and can be used wherever one is.
+//
+//	c := client.New("http://gliner2:8080")
+//	c.APIKey = os.Getenv("GLINER2_API_KEY")
+//	res, err := c.Extract(text, []gliner2.Task{gliner2.Entities("person")}, 0.5, false)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2"
+)
+
+// ErrClosed is returned by calls on a closed Client.
+var ErrClosed = errors.New("gliner2 client: closed")
+
+// Client calls a gliner2-server. Set its fields before the first call; a
+// Client is then safe for concurrent use.
+type Client struct {
+	// BaseURL is the server root, e.g. "http://localhost:8080".
+	BaseURL string
+	// APIKey, if set, is sent in the X-API-Key header.
+	APIKey string
+	// Model selects a named server model (see gliner2-server -models); empty
+	// uses the server's default.
+	Model string
+	// HTTPClient sends the requests; its Timeout bounds each attempt.
+	HTTPClient *http.Client
+	// MaxRetries is how many times a request is retried after a network error
+	// or a 429, 502, 503 or 504 reply.
+	MaxRetries int
+	// Backoff is the wait before the first retry; it doubles for each further
+	// retry. A Retry-After header, when present, takes precedence.
+	Backoff time.Duration
+	// BatchSize caps the texts sent per request by ExtractBatch.
+	BatchSize int
+
+	closed atomic.Bool
+}
+
+var _ gliner2.Extractor = (*Client)(nil)
+
+// New returns a Client for the server at baseURL with a 30s per-attempt
+// timeout, 3 retries starting at 200ms, and batches of 32 texts.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		MaxRetries: 3,
+		Backoff:    200 * time.Millisecond,
+		BatchSize:  32,
+	}
+}
+
+// APIError is a non-2xx reply from the server.
+type APIError struct {
+	StatusCode int
+	Detail     string // the reply's "detail" message, or its raw body
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gliner2 client: server returned %d: %s", e.StatusCode, e.Detail)
+}
+
+// Extract implements gliner2.Extractor.
+func (c *Client) Extract(text string, tasks []gliner2.Task, threshold float32, flatNER bool) (*gliner2.Result, error) {
+	return c.ExtractContext(context.Background(), text, tasks, threshold, flatNER)
+}
+
+// ExtractContext is Extract with a context bounding the call, retries
+// included.
+func (c *Client) ExtractContext(ctx context.Context, text string, tasks []gliner2.Task, threshold float32, flatNER bool) (*gliner2.Result, error) {
+	out, err := c.extract(ctx, []string{text}, tasks, threshold, flatNER)
+	if err != nil {
+		return nil, err
+	}
+	return out[0], nil
+}
+
+// ExtractBatch implements gliner2.Extractor, sending the texts BatchSize at a
+// time.
+func (c *Client) ExtractBatch(texts []string, tasks []gliner2.Task, threshold float32, flatNER bool) ([]*gliner2.Result, error) {
+	return c.ExtractBatchContext(context.Background(), texts, tasks, threshold, flatNER)
+}
+
+// ExtractBatchContext is ExtractBatch with a context.
+func (c *Client) ExtractBatchContext(ctx context.Context, texts []string, tasks []gliner2.Task, threshold float32, flatNER bool) ([]*gliner2.Result, error) {
+	size := c.BatchSize
+	if size <= 0 {
+		size = len(texts)
+	}
+	out := make([]*gliner2.Result, 0, len(texts))
+	for start := 0; start < len(texts); start += size {
+		end := min(start+size, len(texts))
+		res, err := c.extract(ctx, texts[start:end], tasks, threshold, flatNER)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, res...)
+	}
+	return out, nil
+}
+
+// Close implements gliner2.Extractor: it drops idle connections and makes
+// later calls fail with ErrClosed.
+func (c *Client) Close() {
+	c.closed.Store(true)
+	c.httpClient().CloseIdleConnections()
+}
+
+// request is the /v1/extract body.
+type request struct {
+	Model     string         `json:"model,omitempty"`
+	Tasks     []gliner2.Task `json:"tasks"`
+	Documents []document     `json:"documents"`
+	Threshold float32        `json:"threshold"`
+	FlatNER   bool           `json:"flat_ner,omitempty"`
+}
+
+// document is one text of a request. The server echoes ID in its result;
+// the client uses the text's index.
+type document struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// reply is the /v1/extract reply: one result per document, in request order.
+type reply struct {
+	Results []struct {
+		ID     string          `json:"id"`
+		Result *gliner2.Result `json:"result"`
+	} `json:"results"`
+}
+
+// extract sends one request for texts and returns one Result per text.
+func (c *Client) extract(ctx context.Context, texts []string, tasks []gliner2.Task, threshold float32, flatNER bool) ([]*gliner2.Result, error) {
+	if c.closed.Load() {
+		return nil, ErrClosed
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("gliner2 client: at least one task is required")
+	}
+	docs := make([]document, len(texts))
+	for i, text := range texts {
+		docs[i] = document{ID: strconv.Itoa(i), Text: text}
+	}
+	body, err := json.Marshal(request{
+		Model:     c.Model,
+		Tasks:     tasks,
+		Documents: docs,
+		Threshold: threshold,
+		FlatNER:   flatNER,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gliner2 client: marshal request: %w", err)
+	}
+
+	raw, err := c.post(ctx, "/v1/extract", body)
+	if err != nil {
+		return nil, err
+	}
+	var rep reply
+	if err := json.Unmarshal(raw, &rep); err != nil {
+		return nil, fmt.Errorf("gliner2 client: decode reply: %w", err)
+	}
+	if len(rep.Results) != len(texts) {
+		return nil, fmt.Errorf("gliner2 client: server returned %d results for %d texts", len(rep.Results), len(texts))
+	}
+	out := make([]*gliner2.Result, len(texts))
+	for i, r := range rep.Results {
+		if r.ID != docs[i].ID {
+			return nil, fmt.Errorf("gliner2 client: result %d is for document %q, want %q", i, r.ID, docs[i].ID)
+		}
+		out[i] = r.Result
+	}
+	return out, nil
+}
+
+// post sends body to path, retrying transient failures, and returns the 2xx
+// reply body.
+func (c *Client) post(ctx context.Context, path string, body []byte) ([]byte, error) {
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		raw, wait, err := c.attempt(ctx, path, body)
+		if err == nil {
+			return raw, nil
+		}
+		if wait < 0 || attempt >= c.MaxRetries {
+			return nil, err
+		}
+		if wait == 0 {
+			wait = backoff
+			backoff *= 2
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gliner2 client: %w (last error: %v)", ctx.Err(), err)
+		}
+	}
+}
+
+// attempt makes one request. wait is negative when the error is not worth
+// retrying, positive when the server asked for a delay, and zero otherwise.
+func (c *Client) attempt(ctx context.Context, path string, body []byte) (raw []byte, wait time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, -1, fmt.Errorf("gliner2 client: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, -1, fmt.Errorf("gliner2 client: %w", err)
+		}
+		return nil, 0, fmt.Errorf("gliner2 client: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	raw, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gliner2 client: read reply: %w", err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return raw, 0, nil
+	}
+
+	apiErr := &APIError{StatusCode: resp.StatusCode, Detail: strings.TrimSpace(string(raw))}
+	var detail struct {
+		Detail string `json:"detail"`
+	}
+	if json.Unmarshal(raw, &detail) == nil && detail.Detail != "" {
+		apiErr.Detail = detail.Detail
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			return nil, time.Duration(secs) * time.Second, apiErr
+		}
+		return nil, 0, apiErr
+	default:
+		return nil, -1, apiErr
+	}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,152 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2"
+)
+
+// result is what gliner2-server returns for one text of the tasks below: the
+// full gliner2.Result, with token offsets, relation scores and a
+// classification label below the threshold.
+const result = `{
+  "entities": [{"text": "Tim Cook", "label": "person", "score": 0.9, "start_tok": 0, "end_tok": 2, "start_char": 0, "end_char": 8},
+               {"text": "Apple", "label": "company", "score": 0.8, "start_tok": 3, "end_tok": 4, "start_char": 15, "end_char": 20}],
+  "relations": [{"head": {"text": "Tim Cook", "label": "leader", "score": 0.85, "start_tok": 0, "end_tok": 2, "start_char": 0, "end_char": 8},
+                 "tail": {"text": "Apple", "label": "company", "score": 0.75, "start_tok": 3, "end_tok": 4, "start_char": 15, "end_char": 20},
+                 "relation_type": "leads"}],
+  "classifications": [{"task_name": "sentiment", "label": "positive", "score": 0.7},
+                      {"task_name": "sentiment", "label": "negative", "score": 0.3}],
+  "structures": [{"name": "product", "instances": [{"name": "iPhone"}]}]
+}`
+
+var tasks = []gliner2.Task{
+	gliner2.Entities("person", "company"),
+	gliner2.Classifications("sentiment", "positive", "negative"),
+	gliner2.Relations("leads", "leader", "company"),
+	gliner2.Structures("product", gliner2.Field{Name: "name", Dtype: "str"}),
+}
+
+// serve replies to each /v1/extract document with result.
+func serve(t *testing.T, w http.ResponseWriter, r *http.Request) request {
+	t.Helper()
+	if r.URL.Path != "/v1/extract" {
+		t.Errorf("path = %s, want /v1/extract", r.URL.Path)
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		t.Errorf("decode request: %v", err)
+	}
+	results := make([]map[string]any, len(req.Documents))
+	for i, d := range req.Documents {
+		results[i] = map[string]any{"id": d.ID, "result": json.RawMessage(result)}
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"model": "org/model", "results": results})
+	return req
+}
+
+func TestClientExtract(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"detail": "Invalid or expired API key"}`))
+			return
+		}
+		// The first attempt hits a loading server; the client must retry.
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"detail": "model is loading"}`))
+			return
+		}
+		req := serve(t, w, r)
+		if req.Model != "legal" || req.Threshold != 0.5 || !req.FlatNER || len(req.Documents) != 1 {
+			t.Errorf("request = %+v", req)
+		}
+		// Compare as the server decodes them: structure fields become maps.
+		var want []gliner2.Task
+		b, _ := json.Marshal(tasks)
+		_ = json.Unmarshal(b, &want)
+		if !reflect.DeepEqual(req.Tasks, want) {
+			t.Errorf("tasks = %+v, want %+v", req.Tasks, want)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.APIKey = "secret"
+	c.Model = "legal"
+	c.Backoff = time.Millisecond
+	res, err := c.Extract("Tim Cook leads Apple.", tasks, 0.5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("server saw %d calls, want 2 (one retry)", calls.Load())
+	}
+	var want gliner2.Result
+	if err := json.Unmarshal([]byte(result), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*res, want) {
+		t.Errorf("result = %+v, want %+v", *res, want)
+	}
+
+	c.APIKey = "wrong"
+	var apiErr *APIError
+	if _, err := c.Extract("x", tasks, 0.5, false); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("bad key: err = %v, want 401 APIError", err)
+	}
+
+	c.Close()
+	if _, err := c.Extract("x", tasks, 0.5, false); !errors.Is(err, ErrClosed) {
+		t.Errorf("after Close: err = %v, want ErrClosed", err)
+	}
+}
+
+func TestClientBatch(t *testing.T) {
+	var sizes []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := serve(t, w, r)
+		sizes = append(sizes, len(req.Documents))
+		for i, d := range req.Documents {
+			if d.ID != strconv.Itoa(i) {
+				t.Errorf("document %d has id %q", i, d.ID)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.BatchSize = 2
+	res, err := c.ExtractBatch([]string{"a", "b", "c"}, tasks, 0.5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 || len(sizes) != 2 || sizes[0] != 2 || sizes[1] != 1 {
+		t.Errorf("got %d results from batches %v, want 3 from [2 1]", len(res), sizes)
+	}
+}
+
+func TestClientMismatchedReply(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"model": "org/model", "results": [{"id": "7", "result": ` + result + `}]}`))
+	}))
+	defer srv.Close()
+
+	if _, err := New(srv.URL).Extract("x", tasks, 0.5, false); err == nil {
+		t.Error("reply for another document: want an error")
+	}
+	if _, err := New(srv.URL).ExtractBatch([]string{"a", "b"}, tasks, 0.5, false); err == nil {
+		t.Error("reply with too few results: want an error")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !gliner2_noembed
+
+package gliner2
+
+import "embed"
+
+// embeddedLibs reports whether the native artifacts under lib/ are compiled into
+// the binary. Build with -tags gliner2_noembed to omit them (and ship the library
+// separately via GLINER2_LIB_PATH / Config.LibPath).
+const embeddedLibs = true
+
+//go:embed lib
+var libFS embed.FS
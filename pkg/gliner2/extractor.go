@@ -0,0 +1,33 @@
+package gliner2
+
+// Extractor is the extraction API shared by a local *Engine, remote clients
+// and test fakes (see package gliner2test). Code that only runs extractions
+// should depend on Extractor rather than *Engine, so it can be tested without
+// cgo, the native library or model weights.
+type Extractor interface {
+	// Extract runs all tasks over text; see Engine.Extract.
+	Extract(text string, tasks []Task, threshold float32, flatNER bool) (*Result, error)
+	// ExtractBatch runs the same tasks over each text, returning one Result
+	// per text in order. It is a convenience over Extract, not a batched
+	// forward pass.
+	ExtractBatch(texts []string, tasks []Task, threshold float32, flatNER bool) ([]*Result, error)
+	// Close releases the extractor's resources; later calls fail.
+	Close()
+}
+
+var _ Extractor = (*Engine)(nil)
+
+// ExtractBatch calls Extract once per text, one after another, stopping at
+// the first error. The native library has no batched entry point, so this
+// costs the same as calling Extract in a loop.
+func (e *Engine) ExtractBatch(texts []string, tasks []Task, threshold float32, flatNER bool) ([]*Result, error) {
+	out := make([]*Result, 0, len(texts))
+	for _, text := range texts {
+		res, err := e.Extract(text, tasks, threshold, flatNER)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
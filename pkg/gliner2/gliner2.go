@@ -12,8 +12,11 @@
 // The engine downloads model weights from Hugging Face on first use (inside the
 // Rust layer via hf-hub). Because ort is built with the load-dynamic feature,
 // libonnxruntime must be resolvable at runtime: a CPU build is embedded and
-// auto-extracted (ORT_DYLIB_PATH is set to it during Init). To use a GPU build,
-// set ORT_DYLIB_PATH yourself before first use and it will be respected.
+// auto-extracted (ORT_DYLIB_PATH is set to it during Init). Extracted libraries
+// are cached by content hash under $GLINER2_CACHE_DIR (default: the user cache
+// dir) and reused across processes; see Shutdown for the temp-dir fallback. To
+// use a GPU build, set ORT_DYLIB_PATH yourself before first use and it will be
+// respected.
 package gliner2
 
 /*
@@ -24,6 +27,9 @@ package gliner2
 static void* _g2_open_lib(const char* path) {
     return dlopen(path, RTLD_LAZY | RTLD_GLOBAL);
 }
+static void _g2_close_lib(void* handle) {
+    dlclose(handle);
+}
 static char* _g2_get_dlerror(void) {
     return dlerror();
 }
@@ -47,28 +53,37 @@ void _g2_call_free_string(void* f, char* s) {
 const char* _g2_call_last_error(void* f) {
     return ((gliner2_last_error_t)f)();
 }
+int _g2_call_abi_version(void* f) {
+    return ((gliner2_abi_version_t)f)();
+}
+const char* _g2_call_capabilities(void* f) {
+    return ((gliner2_capabilities_t)f)();
+}
 */
 import "C"
 
 import (
-	"compress/gzip"
-	"embed"
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"runtime"
-	"strings"
+	"slices"
 	"sync"
+	"time"
 	"unsafe"
 )
 
-//go:embed lib
-var libFS embed.FS
+// LibPathEnv names the environment variable pointing Init at a gliner2_binding
+// shared library to load instead of the embedded one (e.g. a local build with
+// extra ONNX Runtime features, or a platform without an embedded artifact).
+const LibPathEnv = "GLINER2_LIB_PATH"
 
 var (
-	initOnce sync.Once
-	initErr  error
+	initMu sync.Mutex // held for a whole Init attempt, dlopen included
+
+	statusMu  sync.Mutex
+	initState InitState // written with both initMu and statusMu held
 
 	dlHandle unsafe.Pointer
 
@@ -79,36 +94,6 @@ var (
 	fnLastError  unsafe.Pointer
 )
 
-// extractAndDecompress writes an embedded (optionally gzipped) file to destPath.
-func extractAndDecompress(srcPath, destPath string) error {
-	f, err := libFS.Open(srcPath)
-	if err != nil {
-		return fmt.Errorf("open embedded %s: %w", srcPath, err)
-	}
-	defer func() { _ = f.Close() }()
-
-	var r io.Reader = f
-	if strings.HasSuffix(srcPath, ".gz") {
-		gz, err := gzip.NewReader(f)
-		if err != nil {
-			return fmt.Errorf("gzip reader %s: %w", srcPath, err)
-		}
-		defer func() { _ = gz.Close() }()
-		r = gz
-	}
-
-	out, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o755)
-	if err != nil {
-		return fmt.Errorf("create dest %s: %w", destPath, err)
-	}
-	defer func() { _ = out.Close() }()
-
-	if _, err := io.Copy(out, r); err != nil {
-		return fmt.Errorf("copy %s: %w", srcPath, err)
-	}
-	return nil
-}
-
 // libArtifact returns the embedded library path and on-disk name for the current
 // platform. ORT_DYLIB / onnxruntime resolution is handled separately at runtime.
 func libArtifact() (embedPath, diskName string, err error) {
@@ -154,92 +139,225 @@ func onnxArtifact() (embedPath, diskName string, ok bool) {
 	return "", "", false
 }
 
-// ensureONNXRuntime makes libonnxruntime resolvable for ort's load-dynamic feature.
+// ensureONNXRuntime makes libonnxruntime resolvable for ort's load-dynamic feature
+// and returns the path it will be loaded from ("" for the system default).
 // If the caller already set ORT_DYLIB_PATH (e.g. pointing at an onnxruntime-gpu
-// build), it is respected and nothing is done. Otherwise the bundled CPU build is
-// extracted into tmpDir and ORT_DYLIB_PATH is pointed at it. A missing bundle is
-// not fatal: ort will then fall back to the system loader's default search.
-func ensureONNXRuntime(tmpDir string) {
-	if os.Getenv("ORT_DYLIB_PATH") != "" {
-		return
+// build), it is respected after checking that it exists. Otherwise the bundled
+// CPU build is extracted into the cache under root and ORT_DYLIB_PATH is pointed
+// at it. A missing bundle is not fatal: ort will then fall back to the system
+// loader's default search.
+func ensureONNXRuntime(root string) (string, error) {
+	if p := os.Getenv("ORT_DYLIB_PATH"); p != "" {
+		if _, err := os.Stat(p); err != nil {
+			return p, fmt.Errorf("ORT_DYLIB_PATH: %w", err)
+		}
+		return p, nil
 	}
 	embedPath, diskName, ok := onnxArtifact()
 	if !ok {
-		return
+		return "", nil
 	}
-	dest := filepath.Join(tmpDir, diskName)
-	if err := extractAndDecompress(embedPath, dest); err != nil {
+	dest, err := extractEmbedded(embedPath, diskName, root)
+	if errors.Is(err, fs.ErrNotExist) {
 		// No bundled onnxruntime for this build; rely on the system loader.
-		return
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := os.Setenv("ORT_DYLIB_PATH", dest); err != nil {
+		return dest, fmt.Errorf("set ORT_DYLIB_PATH: %w", err)
 	}
-	_ = os.Setenv("ORT_DYLIB_PATH", dest)
+	return dest, nil
+}
+
+// Config selects the native libraries Init loads. Zero fields fall back to the
+// environment and then to the artifacts embedded in the binary.
+type Config struct {
+	// LibPath is a gliner2_binding shared library to dlopen instead of the
+	// embedded one. Default: $GLINER2_LIB_PATH.
+	LibPath string
+	// ORTPath is the libonnxruntime the engine loads. Default:
+	// $ORT_DYLIB_PATH, else the embedded CPU build. ort reads the path from
+	// ORT_DYLIB_PATH when the first engine is created, so a successful Init
+	// leaves it exported for the life of the process; a failed one restores
+	// the previous value, reporting it in the returned error if it cannot.
+	ORTPath string
+	// ExtractDir is the cache root embedded artifacts are extracted into.
+	// Default: $GLINER2_CACHE_DIR, else the user cache dir.
+	ExtractDir string
 }
 
-// Init extracts and dlopens the gliner2_binding cdylib and resolves its symbols.
-// It is safe to call repeatedly; the work happens once. Most callers do not need
-// to call it directly — New calls it. It returns an error (rather than panicking)
-// when the platform is unsupported or the library/ONNX runtime is unavailable, so
-// a binary built without the native artifact still links and runs.
+// Init extracts and dlopens the gliner2_binding cdylib and resolves its symbols,
+// using the defaults described on Config. The embedded libraries are extracted
+// once into a content-addressed cache ($GLINER2_CACHE_DIR, else the user cache
+// dir) and reused by later processes. Most callers do not need to call it
+// directly — New calls it. It returns an error (rather than panicking) when the
+// platform is unsupported or the library/ONNX runtime is unavailable, so a
+// binary built without the native artifact still links and runs.
+//
+// Once Init succeeds, later calls return nil immediately. A failed attempt is
+// not cached: the next call (e.g. after freeing disk space or fixing
+// ORT_DYLIB_PATH) tries again. Failures are *InitError values naming the stage
+// that failed; InitStatus reports the outcome of the latest attempt.
 func Init() error {
-	initOnce.Do(func() {
+	return InitWithConfig(Config{})
+}
+
+// InitWithConfig is Init with explicit library locations. Once initialization
+// has succeeded the library stays loaded and cfg is ignored, so call it before
+// anything that loads an engine.
+func InitWithConfig(cfg Config) error {
+	initMu.Lock()
+	defer initMu.Unlock()
+	if initState.Ready {
+		return nil
+	}
+
+	st := InitState{Running: true, Attempts: initState.Attempts + 1, LastAttempt: time.Now()}
+	ortEnv, ortSet := os.LookupEnv("ORT_DYLIB_PATH")
+	err := load(cfg, &st)
+	st.Running = false
+	if err != nil {
+		unload()
+		// Leave the environment as we found it, so the next attempt does not
+		// mistake this attempt's ORTPath or extracted copy for the user's.
+		if rerr := restoreEnv("ORT_DYLIB_PATH", ortEnv, ortSet); rerr != nil {
+			err = errors.Join(err, fmt.Errorf("restore ORT_DYLIB_PATH: %w", rerr))
+		}
+		st.Err = err
+		st.Error = err.Error()
+		var ie *InitError
+		if errors.As(err, &ie) {
+			st.Stage = ie.Stage
+		}
+	} else {
+		st.Stage = ""
+		st.Ready = true
+	}
+	publishStatus(st)
+	return err
+}
+
+// restoreEnv sets key back to value, or unsets it if it was not set.
+func restoreEnv(key, value string, set bool) error {
+	if set {
+		return os.Setenv(key, value)
+	}
+	return os.Unsetenv(key)
+}
+
+// publishStatus makes st what InitStatus reports. Init calls it as each stage
+// starts, so a health check during a slow dlopen sees where it is.
+func publishStatus(st InitState) {
+	st.Capabilities = slices.Clone(st.Capabilities)
+	statusMu.Lock()
+	initState = st
+	statusMu.Unlock()
+}
+
+// enterStage records that the attempt st has reached stage.
+func enterStage(st *InitState, stage InitStage) {
+	st.Stage = stage
+	publishStatus(*st)
+}
+
+// load runs the initialization stages, recording what it resolved in st.
+func load(cfg Config, st *InitState) error {
+	if cfg.LibPath == "" {
+		cfg.LibPath = os.Getenv(LibPathEnv)
+	}
+
+	enterStage(st, StageExtract)
+	dest := cfg.LibPath
+	legacy := false // an embedded artifact predating the ABI handshake
+	if dest == "" {
+		if !embeddedLibs {
+			return &InitError{Stage: StageExtract, Err: fmt.Errorf("built with -tags gliner2_noembed; set %s or Config.LibPath", LibPathEnv)}
+		}
 		embedPath, diskName, err := libArtifact()
 		if err != nil {
-			initErr = err
-			return
+			return &InitError{Stage: StageExtract, Err: err}
 		}
-
-		tmpDir, err := os.MkdirTemp("", "go-gliner2-lib")
+		dest, err = extractEmbedded(embedPath, diskName, cfg.ExtractDir)
 		if err != nil {
-			initErr = fmt.Errorf("gliner2: temp dir: %w", err)
-			return
+			return &InitError{Stage: StageExtract, Err: fmt.Errorf("extract native library (build it with the Makefile's gliner2 target): %w", err)}
 		}
-		dest := filepath.Join(tmpDir, diskName)
-		if err := extractAndDecompress(embedPath, dest); err != nil {
-			initErr = fmt.Errorf("gliner2: extract native library (build it with the Makefile's gliner2 target): %w", err)
-			return
+		legacy = isLegacyArtifact(embedPath)
+	}
+	st.LibPath = dest
+
+	// Make libonnxruntime resolvable (ort load-dynamic) before the binding is
+	// dlopen'd / first used. Respects a user-set ORT_DYLIB_PATH for GPU builds.
+	enterStage(st, StageONNXRuntime)
+	if cfg.ORTPath != "" {
+		if err := os.Setenv("ORT_DYLIB_PATH", cfg.ORTPath); err != nil {
+			return &InitError{Stage: StageONNXRuntime, Path: cfg.ORTPath, Err: fmt.Errorf("set ORT_DYLIB_PATH: %w", err)}
 		}
+	}
+	ortPath, err := ensureONNXRuntime(cfg.ExtractDir)
+	st.ORTPath = ortPath
+	if err != nil {
+		return &InitError{Stage: StageONNXRuntime, Path: ortPath, Err: err}
+	}
 
-		// Make libonnxruntime resolvable (ort load-dynamic) before the binding is
-		// dlopen'd / first used. Respects a user-set ORT_DYLIB_PATH for GPU builds.
-		ensureONNXRuntime(tmpDir)
+	enterStage(st, StageDlopen)
+	cDest := C.CString(dest)
+	defer C.free(unsafe.Pointer(cDest))
+	dlHandle = C._g2_open_lib(cDest)
+	if dlHandle == nil {
+		return &InitError{Stage: StageDlopen, Path: dest, Err: errors.New(C.GoString(C._g2_get_dlerror()))}
+	}
 
-		cDest := C.CString(dest)
-		defer C.free(unsafe.Pointer(cDest))
-		dlHandle = C._g2_open_lib(cDest)
-		if dlHandle == nil {
-			initErr = fmt.Errorf("gliner2: dlopen failed: %s", C.GoString(C._g2_get_dlerror()))
-			return
+	loadSym := func(name string) (unsafe.Pointer, error) {
+		cName := C.CString(name)
+		defer C.free(unsafe.Pointer(cName))
+		sym := C._g2_get_sym(dlHandle, cName)
+		if sym == nil {
+			return nil, fmt.Errorf("symbol not found: %s", name)
 		}
+		return sym, nil
+	}
 
-		loadSym := func(name string) (unsafe.Pointer, error) {
-			cName := C.CString(name)
-			defer C.free(unsafe.Pointer(cName))
-			sym := C._g2_get_sym(dlHandle, cName)
-			if sym == nil {
-				return nil, fmt.Errorf("gliner2: symbol not found: %s", name)
-			}
-			return sym, nil
-		}
+	// Handshake first: a stale library may export the same names with different
+	// signatures, so nothing else is trusted until its ABI version matches.
+	enterStage(st, StageABI)
+	version, err := checkABI(dest, legacy, loadSym)
+	if err != nil {
+		return &InitError{Stage: StageABI, Path: dest, Err: err}
+	}
+	st.ABIVersion = version
+	st.Capabilities = capabilities
 
-		for _, s := range []struct {
-			name string
-			dst  *unsafe.Pointer
-		}{
-			{"gliner2_new", &fnNew},
-			{"gliner2_extract", &fnExtract},
-			{"gliner2_free_engine", &fnFreeEngine},
-			{"gliner2_free_string", &fnFreeString},
-			{"gliner2_last_error", &fnLastError},
-		} {
-			sym, e := loadSym(s.name)
-			if e != nil {
-				initErr = e
-				return
-			}
-			*s.dst = sym
+	enterStage(st, StageSymbols)
+	for _, s := range []struct {
+		name string
+		dst  *unsafe.Pointer
+	}{
+		{"gliner2_new", &fnNew},
+		{"gliner2_extract", &fnExtract},
+		{"gliner2_free_engine", &fnFreeEngine},
+		{"gliner2_free_string", &fnFreeString},
+		{"gliner2_last_error", &fnLastError},
+	} {
+		sym, err := loadSym(s.name)
+		if err != nil {
+			return &InitError{Stage: StageSymbols, Path: dest, Err: err}
 		}
-	})
-	return initErr
+		*s.dst = sym
+	}
+	return nil
+}
+
+// unload drops whatever a failed load attempt resolved so the next attempt
+// starts clean (possibly from a different library file).
+func unload() {
+	fnNew, fnExtract, fnFreeEngine, fnFreeString, fnLastError = nil, nil, nil, nil, nil
+	capabilities = nil
+	if dlHandle != nil {
+		C._g2_close_lib(dlHandle)
+		dlHandle = nil
+	}
 }
 
 // lastError returns the engine's thread-local last error message, or "".
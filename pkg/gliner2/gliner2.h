@@ -8,6 +8,8 @@
 // Function types exported by the gliner2_binding Rust cdylib (see
 // gliner2_binding/src/lib.rs). The engine handle is opaque (void*); extraction
 // marshals through a JSON C string the caller must free with gliner2_free_string.
+typedef int (*gliner2_abi_version_t)(void);
+typedef const char *(*gliner2_capabilities_t)(void);
 typedef const char *(*gliner2_last_error_t)(void);
 typedef void *(*gliner2_new_t)(const char *, const char *, int);
 typedef char *(*gliner2_extract_t)(void *, const char *, const char *, float,
@@ -26,5 +28,7 @@ char *_g2_call_extract(void *f, void *eng, const char *text, const char *tasks,
 void _g2_call_free_engine(void *f, void *eng);
 void _g2_call_free_string(void *f, char *s);
 const char *_g2_call_last_error(void *f);
+int _g2_call_abi_version(void *f);
+const char *_g2_call_capabilities(void *f);
 
 #endif
@@ -1,8 +1,23 @@
 package gliner2
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"time"
+	"unsafe"
 )
 
 // TestTaskJSON verifies tasks marshal to the DTO shape the Rust engine expects
@@ -55,6 +70,474 @@ func TestResultDecode(t *testing.T) {
 	}
 }
 
+// TestExtractCached verifies the content-addressed extraction: the artifact lands
+// under <root>/<sha256>/, is reused as-is on the next call, is rewritten when the
+// on-disk copy no longer matches its recorded hash, and concurrent extractions
+// of the same artifact all succeed with identical contents.
+func TestExtractCached(t *testing.T) {
+	payload := bytes.Repeat([]byte("native library bytes\n"), 1024)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"lib/test/libfake.so.gz": {Data: gz.Bytes()}}
+	root := t.TempDir()
+
+	dest, err := extractCached(fsys, "lib/test/libfake.so.gz", root, "libfake.so")
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	key, _ := fsSHA256(fsys, "lib/test/libfake.so.gz")
+	if want := filepath.Join(root, key, "libfake.so"); dest != want {
+		t.Fatalf("dest = %s, want %s", dest, want)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, payload) {
+		t.Fatalf("extracted contents mismatch")
+	}
+
+	// Reuse: the file is not rewritten when its hash still matches.
+	before, _ := os.Stat(dest)
+	if _, err := extractCached(fsys, "lib/test/libfake.so.gz", root, "libfake.so"); err != nil {
+		t.Fatalf("re-extract: %v", err)
+	}
+	if after, _ := os.Stat(dest); !os.SameFile(before, after) {
+		t.Errorf("expected the cached copy to be reused")
+	}
+
+	// Corruption: a tampered copy is detected and replaced.
+	if err := os.WriteFile(dest, []byte("corrupt"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extractCached(fsys, "lib/test/libfake.so.gz", root, "libfake.so"); err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, payload) {
+		t.Errorf("corrupted copy was not re-extracted")
+	}
+
+	// Concurrency: parallel extractors into a fresh root all agree.
+	root2 := t.TempDir()
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p, err := extractCached(fsys, "lib/test/libfake.so.gz", root2, "libfake.so")
+			if err == nil {
+				if got, _ := os.ReadFile(p); !bytes.Equal(got, payload) {
+					err = os.ErrInvalid
+				}
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent extract: %v", err)
+		}
+	}
+	if stale, _ := filepath.Glob(filepath.Join(root2, key, "libfake.so.tmp-*")); len(stale) != 0 {
+		t.Errorf("temp files left behind: %v", stale)
+	}
+}
+
+// TestCheckABILegacy verifies a library without the handshake symbols is
+// refused, unless it is a known pre-handshake embedded artifact, which loads
+// with the capabilities of the builds that predate it.
+func TestCheckABILegacy(t *testing.T) {
+	saved := capabilities
+	t.Cleanup(func() { capabilities = saved })
+	capabilities = nil
+	missing := func(name string) (unsafe.Pointer, error) {
+		return nil, fmt.Errorf("symbol not found: %s", name)
+	}
+
+	if _, err := checkABI("/opt/libgliner2_binding.so", false, missing); err == nil || !strings.Contains(err.Error(), "no ABI version") {
+		t.Fatalf("checkABI without the handshake = %v, want a no-ABI-version error", err)
+	}
+	if capabilities != nil {
+		t.Errorf("refused library set capabilities %v", capabilities)
+	}
+	if isLegacyArtifact("lib/missing/libgliner2_binding.so.gz") {
+		t.Error("isLegacyArtifact accepted an artifact that is not embedded")
+	}
+
+	v, err := checkABI("libgliner2_binding.so", true, missing)
+	if err != nil || v != 0 {
+		t.Fatalf("checkABI = %d, %v; want 0, nil", v, err)
+	}
+	if !slices.Equal(capabilities, legacyCapabilities) {
+		t.Errorf("capabilities = %v, want %v", capabilities, legacyCapabilities)
+	}
+	if err := checkTasks([]Task{Entities("person"), Structures("product", Field{Name: "name"})}, true); err != nil {
+		t.Errorf("legacy library rejected a supported schema: %v", err)
+	}
+}
+
+// TestCheckTasks verifies optional task kinds are gated on the capabilities the
+// native library reports.
+func TestCheckTasks(t *testing.T) {
+	saved := capabilities
+	defer func() { capabilities = saved }()
+	capabilities = []string{CapEntities, CapRelations, CapClassifications}
+
+	if err := checkTasks([]Task{Entities("person"), Relations("works_at", "head", "tail")}, false); err != nil {
+		t.Errorf("supported tasks rejected: %v", err)
+	}
+	if _, err := (&Engine{}).Extract("text", nil, 0.5, false); !errors.Is(err, ErrInvalidTasks) {
+		t.Errorf("Extract without tasks: err = %v, want ErrInvalidTasks", err)
+	}
+	if err := checkTasks([]Task{Structures("product", Field{Name: "name"})}, false); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("structure tasks without the %q capability: err = %v, want ErrUnsupported", CapStructure, err)
+	}
+	if err := checkTasks([]Task{Entities("person")}, true); err == nil {
+		t.Errorf("expected an error for flat NER without the %q capability", CapFlatNER)
+	}
+}
+
+// TestInitRetry verifies a failed Init is not cached: each call retries, and the
+// failing stage is reported both on the error and through InitStatus.
+func TestInitRetry(t *testing.T) {
+	if InitStatus().Ready {
+		t.Skip("native library already initialized by an earlier test")
+	}
+	missing := filepath.Join(t.TempDir(), "libgliner2_missing.so")
+
+	before := InitStatus().Attempts
+	for i := 0; i < 2; i++ {
+		err := InitWithConfig(Config{LibPath: missing})
+		var ie *InitError
+		if !errors.As(err, &ie) || ie.Stage != StageDlopen || ie.Path != missing {
+			t.Fatalf("attempt %d: got %v, want an InitError at stage %q", i, err, StageDlopen)
+		}
+	}
+	st := InitStatus()
+	if st.Ready || st.Stage != StageDlopen || st.Error == "" || st.LibPath != missing {
+		t.Errorf("unexpected status after dlopen failure: %+v", st)
+	}
+	if st.Attempts != before+2 {
+		t.Errorf("attempts = %d, want %d", st.Attempts, before+2)
+	}
+
+	if embeddedLibs {
+		t.Setenv("ORT_DYLIB_PATH", filepath.Join(t.TempDir(), "libonnxruntime_missing.so"))
+		err := Init()
+		var ie *InitError
+		if !errors.As(err, &ie) || ie.Stage != StageONNXRuntime {
+			t.Errorf("bad ORT_DYLIB_PATH: got %v, want an InitError at stage %q", err, StageONNXRuntime)
+		}
+	}
+}
+
+// TestInitStatusDuringInit verifies InitStatus answers while an Init attempt
+// holds initMu (e.g. blocked in dlopen), as health endpoints rely on.
+func TestInitStatusDuringInit(t *testing.T) {
+	initMu.Lock()
+	defer initMu.Unlock()
+
+	done := make(chan InitState, 1)
+	go func() { done <- InitStatus() }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("InitStatus blocked behind an Init attempt")
+	}
+}
+
+// TestInitConfigResolution verifies where Init looks for the binding and
+// onnxruntime: Config.LibPath over $GLINER2_LIB_PATH, Config.ExtractDir for
+// the embedded artifacts, and that a failed attempt leaves ORT_DYLIB_PATH as
+// it found it.
+func TestInitConfigResolution(t *testing.T) {
+	if InitStatus().Ready {
+		t.Skip("native library already initialized by an earlier test")
+	}
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "libgliner2_missing.so")
+	fromEnv := filepath.Join(dir, "libgliner2_from_env.so")
+	ort := filepath.Join(dir, "libonnxruntime.so")
+	if err := os.WriteFile(ort, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ORT_DYLIB_PATH", "")
+	os.Unsetenv("ORT_DYLIB_PATH")
+
+	wantStage := func(t *testing.T, err error, stage InitStage, path string) {
+		t.Helper()
+		var ie *InitError
+		if !errors.As(err, &ie) || ie.Stage != stage || ie.Path != path {
+			t.Fatalf("got %v, want an InitError at stage %q for %s", err, stage, path)
+		}
+	}
+
+	t.Run("LibPath", func(t *testing.T) {
+		t.Setenv(LibPathEnv, fromEnv)
+		wantStage(t, InitWithConfig(Config{LibPath: missing, ORTPath: ort}), StageDlopen, missing)
+	})
+	t.Run("LibPathEnv", func(t *testing.T) {
+		t.Setenv(LibPathEnv, fromEnv)
+		wantStage(t, InitWithConfig(Config{ORTPath: ort}), StageDlopen, fromEnv)
+		if st := InitStatus(); st.LibPath != fromEnv || st.ORTPath != ort {
+			t.Errorf("status resolved lib %q, ort %q; want %q, %q", st.LibPath, st.ORTPath, fromEnv, ort)
+		}
+	})
+	t.Run("ORTPath", func(t *testing.T) {
+		bad := filepath.Join(dir, "libonnxruntime_missing.so")
+		wantStage(t, InitWithConfig(Config{LibPath: missing, ORTPath: bad}), StageONNXRuntime, bad)
+	})
+	t.Run("ExtractDir", func(t *testing.T) {
+		if !embeddedLibs {
+			t.Skip("built with -tags gliner2_noembed")
+		}
+		if _, _, err := libArtifact(); err != nil {
+			t.Skip(err)
+		}
+		// A missing onnxruntime stops Init right after the binding is
+		// extracted, before anything is dlopen'd.
+		t.Setenv(LibPathEnv, "")
+		extractDir := t.TempDir()
+		bad := filepath.Join(dir, "libonnxruntime_missing.so")
+		wantStage(t, InitWithConfig(Config{ExtractDir: extractDir, ORTPath: bad}), StageONNXRuntime, bad)
+		if st := InitStatus(); !strings.HasPrefix(st.LibPath, extractDir+string(filepath.Separator)) {
+			t.Errorf("binding extracted to %q, want it under %s", st.LibPath, extractDir)
+		}
+	})
+	t.Run("Noembed", func(t *testing.T) {
+		if embeddedLibs {
+			t.Skip("embedded libraries are compiled in")
+		}
+		t.Setenv(LibPathEnv, "")
+		wantStage(t, Init(), StageExtract, "")
+	})
+
+	if p, ok := os.LookupEnv("ORT_DYLIB_PATH"); ok {
+		t.Errorf("failed Init left ORT_DYLIB_PATH=%q set", p)
+	}
+}
+
+func TestRestoreEnv(t *testing.T) {
+	const key = "GLINER2_TEST_RESTORE_ENV"
+	t.Setenv(key, "attempt")
+	if err := restoreEnv(key, "before", true); err != nil || os.Getenv(key) != "before" {
+		t.Errorf("restore set value: err %v, got %q", err, os.Getenv(key))
+	}
+	if err := restoreEnv(key, "", false); err != nil {
+		t.Errorf("restore unset: %v", err)
+	}
+	if _, ok := os.LookupEnv(key); ok {
+		t.Errorf("restore unset left %s set", key)
+	}
+	if err := restoreEnv(key, "bad\x00value", true); err == nil {
+		t.Error("restoring a value with a NUL reported no error")
+	}
+}
+
+// fakeNative swaps the engine's native entry points for in-memory fakes that
+// detect use-after-free, and restores them when the test ends.
+type fakeNative struct {
+	freed   atomic.Int32 // number of nativeFree calls
+	uaf     atomic.Int32 // extracts that ran on a freed engine
+	started chan struct{}
+	block   chan struct{} // extract waits on this when non-nil
+}
+
+func installFakeNative(t *testing.T, block bool) *fakeNative {
+	t.Helper()
+	f := &fakeNative{started: make(chan struct{}, 1)}
+	if block {
+		f.block = make(chan struct{})
+	}
+	savedExtract, savedFree, savedCaps := nativeExtract, nativeFree, capabilities
+	t.Cleanup(func() { nativeExtract, nativeFree, capabilities = savedExtract, savedFree, savedCaps })
+	capabilities = []string{CapEntities}
+	nativeExtract = func(ptr unsafe.Pointer, text, tasks string, threshold float32, flat bool) (string, error) {
+		select {
+		case f.started <- struct{}{}:
+		default:
+		}
+		if f.block != nil {
+			<-f.block
+		}
+		if f.freed.Load() > 0 {
+			f.uaf.Add(1)
+		}
+		return `{"entities":[]}`, nil
+	}
+	nativeFree = func(unsafe.Pointer) { f.freed.Add(1) }
+	return f
+}
+
+// TestEngineCloseWaitsForExtract verifies Close blocks until an in-flight
+// Extract returns, frees exactly once, and rejects later calls with ErrClosed.
+func TestEngineCloseWaitsForExtract(t *testing.T) {
+	f := installFakeNative(t, true)
+	eng := newEngine("test/repo", unsafe.Pointer(new(int)))
+	tasks := []Task{Entities("person")}
+
+	extractDone := make(chan error, 1)
+	go func() {
+		_, err := eng.Extract("text", tasks, 0.5, false)
+		extractDone <- err
+	}()
+	<-f.started
+
+	if err := eng.TryClose(); err != ErrBusy {
+		t.Fatalf("TryClose during Extract = %v, want ErrBusy", err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		eng.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while Extract was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(f.block)
+	if err := <-extractDone; err != nil {
+		t.Fatalf("in-flight Extract: %v", err)
+	}
+	<-closed
+
+	if n := f.freed.Load(); n != 1 {
+		t.Errorf("native engine freed %d times, want 1", n)
+	}
+	if f.uaf.Load() != 0 {
+		t.Errorf("Extract ran on a freed engine")
+	}
+	if _, err := eng.Extract("text", tasks, 0.5, false); err != ErrClosed {
+		t.Errorf("Extract after Close = %v, want ErrClosed", err)
+	}
+	eng.Close() // idempotent
+	if n := f.freed.Load(); n != 1 {
+		t.Errorf("second Close freed again (%d frees)", n)
+	}
+}
+
+// TestEngineConcurrentClose verifies concurrent Closes waiting on the same
+// in-flight call free the engine exactly once.
+func TestEngineConcurrentClose(t *testing.T) {
+	f := installFakeNative(t, true)
+	eng := newEngine("test/repo", unsafe.Pointer(new(int)))
+
+	extractDone := make(chan error, 1)
+	go func() {
+		_, err := eng.Extract("text", []Task{Entities("person")}, 0.5, false)
+		extractDone <- err
+	}()
+	<-f.started
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			eng.Close()
+		}()
+	}
+	time.Sleep(50 * time.Millisecond) // let both Closes reach the wait
+	close(f.block)
+	if err := <-extractDone; err != nil {
+		t.Fatalf("in-flight Extract: %v", err)
+	}
+	wg.Wait()
+	if err := eng.TryClose(); err != nil {
+		t.Errorf("TryClose after Close = %v, want nil", err)
+	}
+
+	if n := f.freed.Load(); n != 1 {
+		t.Errorf("native engine freed %d times, want 1", n)
+	}
+}
+
+// TestEngineConcurrentExtractClose hammers an engine from many goroutines while
+// it is closed; run with -race. Every Extract either completes before the free
+// or fails with ErrClosed.
+func TestEngineConcurrentExtractClose(t *testing.T) {
+	f := installFakeNative(t, false)
+	eng := newEngine("test/repo", unsafe.Pointer(new(int)))
+	tasks := []Task{Entities("person")}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if _, err := eng.Extract("text", tasks, 0.5, false); err != nil {
+					if err != ErrClosed {
+						t.Errorf("Extract: %v", err)
+					}
+					return
+				}
+			}
+		}()
+	}
+	<-f.started
+	eng.Close()
+	wg.Wait()
+
+	if n := f.freed.Load(); n != 1 {
+		t.Errorf("native engine freed %d times, want 1", n)
+	}
+	if n := f.uaf.Load(); n != 0 {
+		t.Errorf("%d Extract calls ran on a freed engine", n)
+	}
+}
+
+// TestEngineFinalizer verifies an engine dropped without Close is freed by the
+// garbage collector.
+func TestEngineFinalizer(t *testing.T) {
+	f := installFakeNative(t, false)
+	_ = newEngine("test/leaked", unsafe.Pointer(new(int)))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for f.freed.Load() == 0 && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := f.freed.Load(); n != 1 {
+		t.Errorf("leaked engine freed %d times, want 1", n)
+	}
+}
+
+// TestWarmup verifies Warmup runs every warmup input through the engine and
+// stops early once its context is cancelled.
+func TestWarmup(t *testing.T) {
+	installFakeNative(t, false)
+	capabilities = []string{CapEntities, CapRelations, CapClassifications}
+	var calls atomic.Int32
+	nativeExtract = func(unsafe.Pointer, string, string, float32, bool) (string, error) {
+		calls.Add(1)
+		return `{}`, nil
+	}
+	eng := newEngine("test/repo", unsafe.Pointer(new(int)))
+	defer eng.Close()
+
+	if err := eng.Warmup(context.Background(), nil); err != nil {
+		t.Fatalf("warmup: %v", err)
+	}
+	if n := int(calls.Load()); n != len(warmupTexts) {
+		t.Errorf("warmup ran %d extractions, want %d", n, len(warmupTexts))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := eng.Warmup(ctx, nil); err != context.Canceled {
+		t.Errorf("warmup with cancelled context = %v, want context.Canceled", err)
+	}
+}
+
 func TestAvailableONNXProviders(t *testing.T) {
 	providers, err := AvailableONNXProviders()
 	if err != nil {
@@ -138,3 +621,218 @@ func TestClassifyAndStructuresSmoke(t *testing.T) {
 	}
 	t.Logf("structures: %+v", js.Structures[0].Instances)
 }
+
+// TestRegistry covers lazy loading, LRU eviction under the memory budget,
+// pinning of engines in use, and retry after a failed load.
+func TestRegistry(t *testing.T) {
+	f := installFakeNative(t, false)
+	r := NewRegistry(map[string]ModelConfig{
+		"a":   {Repo: "org/a", MemoryBytes: 100},
+		"b":   {Repo: "org/b", MemoryBytes: 100},
+		"c":   {Repo: "org/c", MemoryBytes: 100},
+		"bad": {Repo: "org/bad"},
+	}, 250)
+	var mu sync.Mutex
+	loads := map[string]int{}
+	failBad := true
+	r.load = func(cfg ModelConfig) (Extractor, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loads[cfg.Repo]++
+		if cfg.Repo == "org/bad" && failBad {
+			return nil, errors.New("boom")
+		}
+		return newEngine(cfg.Repo, unsafe.Pointer(new(int))), nil
+	}
+	defer r.Close()
+	ctx := context.Background()
+	tasks := []Task{Entities("person")}
+
+	// Concurrent first use loads once.
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.Extract(ctx, "a", "text", tasks, 0.5, false); err != nil {
+				t.Errorf("Extract(a): %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if loads["org/a"] != 1 {
+		t.Fatalf("org/a loaded %d times, want 1", loads["org/a"])
+	}
+
+	if _, err := r.Extract(ctx, "b", "text", tasks, 0.5, false); err != nil {
+		t.Fatal(err)
+	}
+	// Touch a so b is least recently used, then load c: b goes.
+	if _, err := r.Extract(ctx, "a", "text", tasks, 0.5, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Extract(ctx, "c", "text", tasks, 0.5, false); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := r.ExtractBatch(ctx, "c", []string{"one", "two"}, tasks, 0.5, false); err != nil || len(res) != 2 {
+		t.Fatalf("ExtractBatch(c) = %d results, %v; want 2", len(res), err)
+	}
+	loaded := map[string]bool{}
+	for _, st := range r.Models() {
+		loaded[st.Name] = st.Loaded
+	}
+	if !loaded["a"] || loaded["b"] || !loaded["c"] {
+		t.Fatalf("after eviction loaded = %v, want a and c", loaded)
+	}
+	if r.Loading("a") || r.Loading("b") {
+		t.Error("Loading reports a model that is loaded or evicted")
+	}
+	if n := f.freed.Load(); n != 1 {
+		t.Fatalf("freed %d engines, want 1", n)
+	}
+
+	// Held engines are not evicted, even over budget.
+	engA, releaseA, err := r.Acquire(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engC, releaseC, err := r.Acquire(ctx, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Extract(ctx, "b", "text", tasks, 0.5, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engA.Extract("text", tasks, 0.5, false); err != nil {
+		t.Errorf("held engine a: %v", err)
+	}
+	if _, err := engC.Extract("text", tasks, 0.5, false); err != nil {
+		t.Errorf("held engine c: %v", err)
+	}
+	releaseA()
+	releaseA()   // idempotent
+	engC.Close() // releases, like releaseC
+	releaseC()
+	if loads["org/b"] != 2 {
+		t.Errorf("org/b loaded %d times, want 2 (reload after eviction)", loads["org/b"])
+	}
+
+	if _, err := r.Extract(ctx, "nope", "text", tasks, 0.5, false); !errors.Is(err, ErrUnknownModel) {
+		t.Errorf("unknown model: err = %v, want ErrUnknownModel", err)
+	}
+	if _, err := r.Extract(ctx, "bad", "text", tasks, 0.5, false); err == nil {
+		t.Fatal("failed load: want error")
+	}
+	mu.Lock()
+	failBad = false
+	mu.Unlock()
+	if _, err := r.Extract(ctx, "bad", "text", tasks, 0.5, false); err != nil {
+		t.Errorf("retry after failed load: %v", err)
+	}
+
+	r.Close()
+	if _, err := r.Extract(ctx, "a", "text", tasks, 0.5, false); !errors.Is(err, ErrRegistryClosed) {
+		t.Errorf("after Close: err = %v, want ErrRegistryClosed", err)
+	}
+}
+
+// overlapProbe is an Extractor that records the most calls it saw at once.
+type overlapProbe struct {
+	active, max atomic.Int32
+}
+
+func (p *overlapProbe) Extract(string, []Task, float32, bool) (*Result, error) {
+	n := p.active.Add(1)
+	defer p.active.Add(-1)
+	for {
+		m := p.max.Load()
+		if n <= m || p.max.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	return &Result{}, nil
+}
+
+func (p *overlapProbe) ExtractBatch(texts []string, tasks []Task, threshold float32, flatNER bool) ([]*Result, error) {
+	res, err := p.Extract("", tasks, threshold, flatNER)
+	return []*Result{res}, err
+}
+
+func (p *overlapProbe) Close() {}
+
+// TestRegistryAcquireSerializes verifies calls through an acquired Extractor
+// are serialized with Registry.Extract calls on the same model.
+func TestRegistryAcquireSerializes(t *testing.T) {
+	probe := &overlapProbe{}
+	r := NewRegistryWithLoader(map[string]ModelConfig{"a": {Repo: "org/a"}}, 0, func(ModelConfig) (Extractor, error) {
+		return probe, nil
+	})
+	defer r.Close()
+	ctx := context.Background()
+	tasks := []Task{Entities("person")}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				if _, err := r.Extract(ctx, "a", "text", tasks, 0.5, false); err != nil {
+					t.Error(err)
+				}
+				return
+			}
+			eng, release, err := r.Acquire(ctx, "a")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer release()
+			if _, err := eng.Extract("text", tasks, 0.5, false); err != nil {
+				t.Error(err)
+			}
+			if _, err := eng.ExtractBatch([]string{"text"}, tasks, 0.5, false); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := probe.max.Load(); n != 1 {
+		t.Errorf("%d calls ran on the model at once, want 1", n)
+	}
+	if st := r.Models(); st[0].InUse != 0 {
+		t.Errorf("model still in use by %d callers after release", st[0].InUse)
+	}
+}
+
+// TestWarmupEntitiesOnly verifies the default warmup schema sticks to the
+// tasks an entities-only library supports.
+func TestWarmupEntitiesOnly(t *testing.T) {
+	installFakeNative(t, false)
+	var schemas []string
+	nativeExtract = func(_ unsafe.Pointer, _, tasks string, _ float32, _ bool) (string, error) {
+		schemas = append(schemas, tasks)
+		return `{}`, nil
+	}
+	eng := newEngine("test/repo", unsafe.Pointer(new(int)))
+	defer eng.Close()
+
+	if err := eng.Warmup(context.Background(), nil); err != nil {
+		t.Fatalf("warmup on an entities-only library: %v", err)
+	}
+	if len(schemas) != len(warmupTexts) {
+		t.Fatalf("warmup ran %d extractions, want %d", len(schemas), len(warmupTexts))
+	}
+	for _, s := range schemas {
+		var tasks []Task
+		if err := json.Unmarshal([]byte(s), &tasks); err != nil {
+			t.Fatalf("decode warmup schema %q: %v", s, err)
+		}
+		for _, task := range tasks {
+			if task.Type != CapEntities {
+				t.Errorf("warmup sent a %q task to an entities-only library", task.Type)
+			}
+		}
+	}
+}
@@ -0,0 +1,318 @@
+// Package gliner2test provides a scriptable, pure-Go gliner2.Extractor for
+// tests of code that runs extractions. It needs neither cgo nor the native
+// library nor model weights.
+//
+//	f := gliner2test.New().
+//		AddEntity("person", `Mario Rossi|Tim Cook`).
+//		AddClassification("sentiment", "positive", 0.9)
+//	svc := NewService(f) // takes a gliner2.Extractor
+//
+// Results are built per call from the rules that match the requested tasks;
+// SetResult replaces that with a canned Result for one exact text.
+package gliner2test
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2"
+)
+
+// ErrClosed is returned by calls on a closed Fake.
+var ErrClosed = errors.New("gliner2test: fake is closed")
+
+// Call records one Extract (or one text of an ExtractBatch).
+type Call struct {
+	Text      string
+	Tasks     []gliner2.Task
+	Threshold float32
+	FlatNER   bool
+}
+
+// Fake is a scriptable gliner2.Extractor. Configure it before use; the
+// configuration methods return the Fake for chaining and, like Extract, are
+// safe for concurrent use.
+type Fake struct {
+	mu       sync.Mutex
+	canned   map[string]*gliner2.Result
+	entities []entityRule
+	classes  map[string][]gliner2.Classification // by task name
+	rels     map[string][][2]string              // by relation type: head/tail texts
+	errAll   error
+	errText  map[string]error
+	latency  time.Duration
+	calls    []Call
+	closed   bool
+}
+
+type entityRule struct {
+	label string
+	re    *regexp.Regexp
+	score float32
+}
+
+var _ gliner2.Extractor = (*Fake)(nil)
+
+// New returns an empty Fake: every call succeeds with an empty Result.
+func New() *Fake {
+	return &Fake{
+		canned:  map[string]*gliner2.Result{},
+		classes: map[string][]gliner2.Classification{},
+		rels:    map[string][][2]string{},
+		errText: map[string]error{},
+	}
+}
+
+// SetResult makes Extract return a copy of res, unfiltered, whenever the text
+// is exactly text. Each call gets its own copy, so callers may modify it.
+func (f *Fake) SetResult(text string, res *gliner2.Result) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.canned[text] = res
+	return f
+}
+
+// AddEntity reports every match of the regular expression pattern as an
+// entity labelled label (score 1), when an entity task requests that label.
+// Offsets are byte offsets into the text. It panics if pattern does not
+// compile, like regexp.MustCompile.
+func (f *Fake) AddEntity(label, pattern string) *Fake {
+	return f.AddEntityScore(label, pattern, 1)
+}
+
+// AddEntityScore is AddEntity with a score, which Extract compares against
+// the threshold.
+func (f *Fake) AddEntityScore(label, pattern string, score float32) *Fake {
+	re := regexp.MustCompile(pattern)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entities = append(f.entities, entityRule{label: label, re: re, score: score})
+	return f
+}
+
+// AddClassification reports label with score for the classification task
+// named task, when that task requests label. Scores are reported as is,
+// regardless of threshold, as the engine does.
+func (f *Fake) AddClassification(task, label string, score float32) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.classes[task] = append(f.classes[task], gliner2.Classification{TaskName: task, Label: label, Score: score})
+	return f
+}
+
+// AddRelation reports a relation of type name from head to tail for a
+// relations task named name, whenever both texts occur in the input.
+func (f *Fake) AddRelation(name, head, tail string) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rels[name] = append(f.rels[name], [2]string{head, tail})
+	return f
+}
+
+// SetError makes every call fail with err; nil clears it.
+func (f *Fake) SetError(err error) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errAll = err
+	return f
+}
+
+// FailOn makes calls for exactly text fail with err.
+func (f *Fake) FailOn(text string, err error) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errText[text] = err
+	return f
+}
+
+// SetLatency makes each call sleep for d before answering.
+func (f *Fake) SetLatency(d time.Duration) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = d
+	return f
+}
+
+// Calls returns the calls made so far, in order.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// Extract implements gliner2.Extractor.
+func (f *Fake) Extract(text string, tasks []gliner2.Task, threshold float32, flatNER bool) (*gliner2.Result, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{Text: text, Tasks: tasks, Threshold: threshold, FlatNER: flatNER})
+	latency := f.latency
+	f.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case f.closed:
+		return nil, ErrClosed
+	case f.errAll != nil:
+		return nil, f.errAll
+	case f.errText[text] != nil:
+		return nil, f.errText[text]
+	case len(tasks) == 0:
+		return nil, fmt.Errorf("%w: at least one task is required", gliner2.ErrInvalidTasks)
+	}
+	if res, ok := f.canned[text]; ok {
+		return copyResult(res), nil
+	}
+
+	res := &gliner2.Result{
+		Entities:        []gliner2.Entity{},
+		Relations:       []gliner2.Relation{},
+		Classifications: []gliner2.Classification{},
+		Structures:      []gliner2.Structure{},
+	}
+	for _, t := range tasks {
+		switch t.Type {
+		case "entities":
+			res.Entities = append(res.Entities, f.matchEntities(text, t.Labels, threshold)...)
+		case "classifications":
+			for _, c := range f.classes[t.Name] {
+				if slices.Contains(t.Labels, c.Label) {
+					res.Classifications = append(res.Classifications, c)
+				}
+			}
+		case "relations":
+			for _, ht := range f.rels[t.Name] {
+				head, tail := mention(text, ht[0]), mention(text, ht[1])
+				if head != nil && tail != nil {
+					res.Relations = append(res.Relations, gliner2.Relation{Head: *head, Tail: *tail, RelationType: t.Name})
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// ExtractBatch implements gliner2.Extractor by calling Extract per text.
+func (f *Fake) ExtractBatch(texts []string, tasks []gliner2.Task, threshold float32, flatNER bool) ([]*gliner2.Result, error) {
+	out := make([]*gliner2.Result, 0, len(texts))
+	for _, text := range texts {
+		res, err := f.Extract(text, tasks, threshold, flatNER)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
+
+// Close implements gliner2.Extractor; later calls fail with ErrClosed.
+func (f *Fake) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+}
+
+// Closed reports whether Close was called.
+func (f *Fake) Closed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+func (f *Fake) matchEntities(text string, labels []string, threshold float32) []gliner2.Entity {
+	var out []gliner2.Entity
+	for _, r := range f.entities {
+		if r.score < threshold || !slices.Contains(labels, r.label) {
+			continue
+		}
+		for _, loc := range r.re.FindAllStringIndex(text, -1) {
+			out = append(out, gliner2.Entity{
+				Text:      text[loc[0]:loc[1]],
+				Label:     r.label,
+				Score:     r.score,
+				StartChar: loc[0],
+				EndChar:   loc[1],
+			})
+		}
+	}
+	return out
+}
+
+// copyResult returns a deep copy of res, down to structure instance values.
+func copyResult(res *gliner2.Result) *gliner2.Result {
+	if res == nil {
+		return nil
+	}
+	out := &gliner2.Result{
+		Entities:        slices.Clone(res.Entities),
+		Relations:       slices.Clone(res.Relations),
+		Classifications: slices.Clone(res.Classifications),
+		Structures:      slices.Clone(res.Structures),
+	}
+	for i, st := range out.Structures {
+		out.Structures[i].Instances = slices.Clone(st.Instances)
+		for j, inst := range st.Instances {
+			if inst == nil {
+				continue
+			}
+			c := make(map[string]any, len(inst))
+			for k, v := range inst {
+				switch v := v.(type) {
+				case []string:
+					c[k] = slices.Clone(v)
+				case []any:
+					c[k] = slices.Clone(v)
+				default:
+					c[k] = v
+				}
+			}
+			out.Structures[i].Instances[j] = c
+		}
+	}
+	return out
+}
+
+// mention returns the first occurrence of s in text as an unlabelled entity.
+func mention(text, s string) *gliner2.Entity {
+	i := strings.Index(text, s)
+	if i < 0 || s == "" {
+		return nil
+	}
+	return &gliner2.Entity{Text: s, Score: 1, StartChar: i, EndChar: i + len(s)}
+}
+
+// Loader returns a load function for gliner2.NewRegistryWithLoader. Each
+// load gets its own Fake configured like f at the time of the load, with no
+// calls recorded, so evicting or closing one model leaves f and the others
+// open.
+func (f *Fake) Loader() func(gliner2.ModelConfig) (gliner2.Extractor, error) {
+	return func(gliner2.ModelConfig) (gliner2.Extractor, error) { return f.clone(), nil }
+}
+
+// clone returns an open Fake with f's configuration and no recorded calls.
+func (f *Fake) clone() *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := New()
+	maps.Copy(c.canned, f.canned)
+	c.entities = slices.Clone(f.entities)
+	for k, v := range f.classes {
+		c.classes[k] = slices.Clone(v)
+	}
+	for k, v := range f.rels {
+		c.rels[k] = slices.Clone(v)
+	}
+	c.errAll = f.errAll
+	maps.Copy(c.errText, f.errText)
+	c.latency = f.latency
+	return c
+}
@@ -0,0 +1,105 @@
+package gliner2test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/soundprediction/go-gline-rs/pkg/gliner2"
+)
+
+func TestFakeRules(t *testing.T) {
+	f := New().
+		AddEntity("person", `Mario Rossi|Tim Cook`).
+		AddEntityScore("location", `Cupertino`, 0.4).
+		AddClassification("sentiment", "positive", 0.9).
+		AddClassification("sentiment", "negative", 0.1).
+		AddRelation("works_at", "Mario Rossi", "Apple")
+
+	text := "Mario Rossi works at Apple in Cupertino."
+	res, err := f.Extract(text, []gliner2.Task{
+		gliner2.Entities("person", "location"),
+		gliner2.Classifications("sentiment", "positive"),
+		gliner2.Relations("works_at", "head", "tail"),
+	}, 0.5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Entities) != 1 || res.Entities[0].Text != "Mario Rossi" || res.Entities[0].StartChar != 0 || res.Entities[0].EndChar != 11 {
+		t.Errorf("entities = %+v, want Mario Rossi [0,11) only (Cupertino is below threshold)", res.Entities)
+	}
+	if len(res.Classifications) != 1 || res.Classifications[0].Label != "positive" {
+		t.Errorf("classifications = %+v, want only the requested label", res.Classifications)
+	}
+	if len(res.Relations) != 1 || res.Relations[0].Tail.Text != "Apple" || res.Relations[0].Tail.StartChar != 21 {
+		t.Errorf("relations = %+v", res.Relations)
+	}
+
+	res, err = f.Extract("Tim Cook", []gliner2.Task{gliner2.Entities("location")}, 0.5, false)
+	if err != nil || len(res.Entities) != 0 {
+		t.Errorf("unrequested label: entities = %+v, err = %v", res.Entities, err)
+	}
+}
+
+func TestFakeCannedErrorsLatency(t *testing.T) {
+	canned := &gliner2.Result{
+		Entities:   []gliner2.Entity{{Text: "x", Label: "y"}},
+		Structures: []gliner2.Structure{{Name: "s", Instances: []map[string]any{{"f": []string{"a"}}}}},
+	}
+	boom := errors.New("boom")
+	f := New().SetResult("canned", canned).FailOn("bad", boom).SetLatency(20 * time.Millisecond)
+	tasks := []gliner2.Task{gliner2.Entities("y")}
+
+	start := time.Now()
+	res, err := f.Extract("canned", tasks, 0.5, false)
+	if err != nil || !reflect.DeepEqual(res, canned) {
+		t.Errorf("canned: res = %v, err = %v", res, err)
+	}
+	// Each caller gets its own copy.
+	res.Entities[0].Text = "changed"
+	res.Structures[0].Instances[0]["f"].([]string)[0] = "changed"
+	if again, _ := f.Extract("canned", tasks, 0.5, false); again == res || !reflect.DeepEqual(again, canned) {
+		t.Errorf("canned result shared between calls: %+v", again)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("latency not applied")
+	}
+	if _, err := f.ExtractBatch([]string{"ok", "bad"}, tasks, 0.5, false); !errors.Is(err, boom) {
+		t.Errorf("FailOn: err = %v, want boom", err)
+	}
+	if n := len(f.Calls()); n != 4 {
+		t.Errorf("recorded %d calls, want 4", n)
+	}
+
+	f.SetError(boom)
+	if _, err := f.Extract("canned", tasks, 0.5, false); !errors.Is(err, boom) {
+		t.Errorf("SetError: err = %v, want boom", err)
+	}
+	f.Close()
+	if _, err := f.Extract("canned", tasks, 0.5, false); !errors.Is(err, ErrClosed) {
+		t.Errorf("after Close: err = %v, want ErrClosed", err)
+	}
+}
+
+func TestFakeLoader(t *testing.T) {
+	f := New().AddEntity("person", `Mario Rossi`)
+	load := f.Loader()
+	a, err := load(gliner2.ModelConfig{Repo: "org/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := load(gliner2.ModelConfig{Repo: "org/b"})
+	if a == b || a == gliner2.Extractor(f) {
+		t.Fatal("Loader served the same Fake for two loads")
+	}
+
+	a.Close()
+	res, err := b.Extract("Mario Rossi", []gliner2.Task{gliner2.Entities("person")}, 0.5, false)
+	if err != nil || len(res.Entities) != 1 {
+		t.Errorf("other load after closing one = %+v, %v; want Mario Rossi", res, err)
+	}
+	if f.Closed() || len(f.Calls()) != 0 {
+		t.Errorf("closing or calling a loaded fake touched f: closed %v, calls %v", f.Closed(), f.Calls())
+	}
+}
@@ -0,0 +1,13 @@
+//go:build gliner2_noembed
+
+package gliner2
+
+import "embed"
+
+// embeddedLibs is false under the gliner2_noembed tag: no native artifacts are
+// compiled in, so Init needs GLINER2_LIB_PATH or Config.LibPath, and
+// libonnxruntime must come from ORT_DYLIB_PATH / Config.ORTPath or the system.
+const embeddedLibs = false
+
+// libFS is empty; every lookup reports fs.ErrNotExist.
+var libFS embed.FS
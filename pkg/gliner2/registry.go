@@ -0,0 +1,334 @@
+package gliner2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ModelConfig describes a model a Registry can load.
+type ModelConfig struct {
+	Repo      string    `json:"repo"`
+	Variant   string    `json:"variant,omitempty"` // subfolder, as for New
+	ModelType ModelType `json:"model_type"`
+	// MemoryBytes is the estimated resident size of the loaded engine,
+	// charged against the Registry's budget. Zero is not counted.
+	MemoryBytes int64 `json:"memory_bytes,omitempty"`
+	// Warmup runs Engine.Warmup with the default schema after loading.
+	Warmup bool `json:"warmup,omitempty"`
+}
+
+var (
+	// ErrUnknownModel is returned for a model name the Registry has no config for.
+	ErrUnknownModel = errors.New("gliner2: unknown model")
+	// ErrRegistryClosed is returned by calls on a closed Registry.
+	ErrRegistryClosed = errors.New("gliner2: registry is closed")
+)
+
+// Registry maps model names to configs and loads each engine lazily on first
+// use. When the estimated memory of the loaded engines exceeds the budget, the
+// least-recently-used engines that are not in use are closed; they are
+// reloaded on their next use. Engines in use are never evicted, so the budget
+// can be exceeded while every loaded model is busy. A Registry is safe for
+// concurrent use.
+type Registry struct {
+	budget int64
+	load   func(ModelConfig) (Extractor, error)
+
+	mu      sync.Mutex
+	configs map[string]ModelConfig
+	entries map[string]*registryEntry
+	used    int64  // MemoryBytes of entries, loading or loaded
+	clock   uint64 // LRU sequence
+	closed  bool
+}
+
+type registryEntry struct {
+	name string
+	cfg  ModelConfig
+
+	loaded chan struct{} // closed once the load finishes; eng/err are then set
+	eng    Extractor
+	err    error
+	done   bool // guarded by Registry.mu, set with eng/err
+
+	call     sync.Mutex // Engine is not safe for concurrent Extract calls
+	refs     int        // guarded by Registry.mu
+	lastUsed uint64     // guarded by Registry.mu
+}
+
+// ModelStatus describes one configured model, as reported by Registry.Models.
+type ModelStatus struct {
+	Name        string `json:"name"`
+	Repo        string `json:"repo"`
+	Variant     string `json:"variant,omitempty"`
+	MemoryBytes int64  `json:"memory_bytes,omitempty"`
+	Loaded      bool   `json:"loaded"`
+	Loading     bool   `json:"loading,omitempty"`
+	InUse       int    `json:"in_use,omitempty"`
+}
+
+// NewRegistry returns a Registry serving configs. budget is the total
+// MemoryBytes allowed for loaded engines; 0 means unlimited.
+func NewRegistry(configs map[string]ModelConfig, budget int64) *Registry {
+	return NewRegistryWithLoader(configs, budget, loadModel)
+}
+
+// NewRegistryWithLoader is NewRegistry with a custom load function in place of
+// New (plus Warmup when cfg.Warmup is set), e.g. to serve test fakes or
+// remote clients.
+func NewRegistryWithLoader(configs map[string]ModelConfig, budget int64, load func(ModelConfig) (Extractor, error)) *Registry {
+	cfgs := make(map[string]ModelConfig, len(configs))
+	for name, cfg := range configs {
+		cfgs[name] = cfg
+	}
+	return &Registry{
+		budget:  budget,
+		load:    load,
+		configs: cfgs,
+		entries: map[string]*registryEntry{},
+	}
+}
+
+func loadModel(cfg ModelConfig) (Extractor, error) {
+	eng, err := New(cfg.Repo, cfg.Variant, cfg.ModelType)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Warmup {
+		if err := eng.Warmup(context.Background(), nil); err != nil {
+			eng.Close()
+			return nil, err
+		}
+	}
+	return eng, nil
+}
+
+// Acquire returns the engine for name, loading it first if needed, and a
+// release func that must be called when the caller is done with it. The
+// engine is not evicted until released. ctx bounds only the wait: a load, once
+// started, runs to completion for later callers. A failed load is not cached;
+// the next Acquire tries again.
+//
+// The returned Extractor serializes its calls with every other call on the
+// model, like Registry.Extract. Its Close releases it (as the release func
+// does) rather than closing the engine, which the Registry owns.
+func (r *Registry) Acquire(ctx context.Context, name string) (Extractor, func(), error) {
+	e, err := r.acquire(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	h := &heldModel{e: e}
+	h.release = func() { h.once.Do(func() { r.release(e) }) }
+	return h, h.release, nil
+}
+
+// heldModel is the Extractor Acquire returns.
+type heldModel struct {
+	e       *registryEntry
+	once    sync.Once
+	release func()
+}
+
+func (h *heldModel) Extract(text string, tasks []Task, threshold float32, flatNER bool) (*Result, error) {
+	h.e.call.Lock()
+	defer h.e.call.Unlock()
+	return h.e.eng.Extract(text, tasks, threshold, flatNER)
+}
+
+func (h *heldModel) ExtractBatch(texts []string, tasks []Task, threshold float32, flatNER bool) ([]*Result, error) {
+	h.e.call.Lock()
+	defer h.e.call.Unlock()
+	return h.e.eng.ExtractBatch(texts, tasks, threshold, flatNER)
+}
+
+func (h *heldModel) Close() { h.release() }
+
+// Extract runs Extract on the named model, loading it if needed.
+// Calls on the same model are serialized.
+func (r *Registry) Extract(ctx context.Context, name, text string, tasks []Task, threshold float32, flatNER bool) (*Result, error) {
+	e, err := r.acquire(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.release(e)
+	e.call.Lock()
+	defer e.call.Unlock()
+	return e.eng.Extract(text, tasks, threshold, flatNER)
+}
+
+// ExtractBatch runs ExtractBatch on the named model, loading it if needed.
+// Calls on the same model are serialized with Extract calls.
+func (r *Registry) ExtractBatch(ctx context.Context, name string, texts []string, tasks []Task, threshold float32, flatNER bool) ([]*Result, error) {
+	e, err := r.acquire(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.release(e)
+	e.call.Lock()
+	defer e.call.Unlock()
+	return e.eng.ExtractBatch(texts, tasks, threshold, flatNER)
+}
+
+func (r *Registry) acquire(ctx context.Context, name string) (*registryEntry, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, ErrRegistryClosed
+	}
+	cfg, ok := r.configs[name]
+	if !ok {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("%w %q", ErrUnknownModel, name)
+	}
+	e := r.entries[name]
+	var victims []Extractor
+	if e == nil {
+		e = &registryEntry{name: name, cfg: cfg, loaded: make(chan struct{})}
+		r.entries[name] = e
+		r.used += cfg.MemoryBytes
+		victims = r.evictLocked()
+		go r.loadEntry(e)
+	}
+	e.refs++
+	r.mu.Unlock()
+	closeEngines(victims)
+
+	select {
+	case <-e.loaded:
+	case <-ctx.Done():
+		r.release(e)
+		return nil, ctx.Err()
+	}
+	if e.err != nil {
+		r.release(e)
+		return nil, e.err
+	}
+	return e, nil
+}
+
+func (r *Registry) loadEntry(e *registryEntry) {
+	eng, err := r.load(e.cfg)
+	if err != nil {
+		err = fmt.Errorf("gliner2: load model %q: %w", e.name, err)
+	}
+
+	r.mu.Lock()
+	e.eng, e.err, e.done = eng, err, true
+	if err != nil {
+		r.removeLocked(e)
+	}
+	closed := r.closed
+	r.mu.Unlock()
+	close(e.loaded)
+
+	// Close ran while this load was in flight and has already dropped e.
+	if closed && eng != nil {
+		eng.Close()
+	}
+}
+
+func (r *Registry) release(e *registryEntry) {
+	r.mu.Lock()
+	e.refs--
+	r.clock++
+	e.lastUsed = r.clock
+	victims := r.evictLocked()
+	r.mu.Unlock()
+	closeEngines(victims)
+}
+
+// evictLocked drops least-recently-used idle engines until the budget is met
+// (or nothing more can go) and returns them for closing outside the lock.
+func (r *Registry) evictLocked() []Extractor {
+	var victims []Extractor
+	for r.budget > 0 && r.used > r.budget {
+		var lru *registryEntry
+		for _, e := range r.entries {
+			if e.refs > 0 || !e.done || e.cfg.MemoryBytes == 0 {
+				continue
+			}
+			if lru == nil || e.lastUsed < lru.lastUsed {
+				lru = e
+			}
+		}
+		if lru == nil {
+			break
+		}
+		r.removeLocked(lru)
+		victims = append(victims, lru.eng)
+	}
+	return victims
+}
+
+func (r *Registry) removeLocked(e *registryEntry) {
+	if r.entries[e.name] == e {
+		delete(r.entries, e.name)
+		r.used -= e.cfg.MemoryBytes
+	}
+}
+
+func closeEngines(engs []Extractor) {
+	for _, eng := range engs {
+		eng.Close()
+	}
+}
+
+// Models reports every configured model, sorted by name.
+func (r *Registry) Models() []ModelStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]ModelStatus, 0, len(r.configs))
+	for name, cfg := range r.configs {
+		st := ModelStatus{Name: name, Repo: cfg.Repo, Variant: cfg.Variant, MemoryBytes: cfg.MemoryBytes}
+		if e := r.entries[name]; e != nil {
+			st.Loaded = e.done && e.err == nil
+			st.Loading = !e.done
+			st.InUse = e.refs
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Loading reports whether the named model's load is under way, so a caller
+// that must not wait for it (e.g. a server answering 503) can check first.
+func (r *Registry) Loading(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.entries[name]
+	return e != nil && !e.done
+}
+
+// Has reports whether name is a configured model.
+func (r *Registry) Has(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.configs[name]
+	return ok
+}
+
+// Close closes every loaded engine, waiting for in-flight Extract calls, and
+// makes later Acquire calls fail with ErrRegistryClosed. Engines still loading
+// are closed when their load finishes.
+func (r *Registry) Close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	var engs []Extractor
+	for _, e := range r.entries {
+		if e.done && e.eng != nil {
+			engs = append(engs, e.eng)
+		}
+	}
+	r.entries = map[string]*registryEntry{}
+	r.used = 0
+	r.mu.Unlock()
+	closeEngines(engs)
+}
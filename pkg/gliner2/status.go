@@ -0,0 +1,73 @@
+package gliner2
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// InitStage names the step of Init that failed.
+type InitStage string
+
+const (
+	// StageExtract covers locating the binding: the platform check and
+	// extracting the embedded artifact (or a missing GLINER2_LIB_PATH build).
+	StageExtract InitStage = "extract"
+	// StageONNXRuntime covers making libonnxruntime resolvable: a bad
+	// ORT_DYLIB_PATH / Config.ORTPath or a failed extraction of the bundle.
+	StageONNXRuntime InitStage = "onnxruntime"
+	// StageDlopen is the dlopen of the binding itself.
+	StageDlopen InitStage = "dlopen"
+	// StageABI is the ABI version / capability handshake.
+	StageABI InitStage = "abi"
+	// StageSymbols is resolving the binding's exported functions.
+	StageSymbols InitStage = "symbols"
+)
+
+// InitError is the error returned by a failed Init. Stage says which step
+// failed and Path the file involved, if any.
+type InitError struct {
+	Stage InitStage
+	Path  string
+	Err   error
+}
+
+func (e *InitError) Error() string {
+	return fmt.Sprintf("gliner2: init (%s): %v", e.Stage, e.Err)
+}
+
+func (e *InitError) Unwrap() error { return e.Err }
+
+// InitState is a snapshot of native-library initialization, suitable for a
+// service's health endpoint.
+type InitState struct {
+	// Ready is true once Init has succeeded.
+	Ready bool `json:"ready"`
+	// Running is true while an Init attempt is under way; Stage is then the
+	// step it is on.
+	Running bool `json:"running,omitempty"`
+	// Attempts counts Init attempts that did real work (including the
+	// successful one); 0 means Init has not been called yet.
+	Attempts    int       `json:"attempts"`
+	LastAttempt time.Time `json:"last_attempt,omitzero"`
+	// Stage and Error describe the latest failure; both are empty when Ready.
+	Stage InitStage `json:"stage,omitempty"`
+	Error string    `json:"error,omitempty"`
+	Err   error     `json:"-"`
+	// What the latest attempt resolved before it finished (or failed).
+	// ABIVersion is 0 for an embedded artifact predating the ABI handshake.
+	LibPath      string   `json:"lib_path,omitempty"`
+	ORTPath      string   `json:"ort_path,omitempty"`
+	ABIVersion   int      `json:"abi_version,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// InitStatus reports the outcome of the most recent Init attempt without
+// triggering one. It does not wait for an attempt in progress.
+func InitStatus() InitState {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	st := initState
+	st.Capabilities = slices.Clone(st.Capabilities)
+	return st
+}
@@ -7,7 +7,11 @@ import "C"
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"runtime"
+	"sync"
 	"unsafe"
 )
 
@@ -22,6 +26,41 @@ const (
 	ModelTypeHuggingFace ModelType = 1
 )
 
+// String returns "pytorch" or "huggingface".
+func (t ModelType) String() string {
+	switch t {
+	case ModelTypePyTorch:
+		return "pytorch"
+	case ModelTypeHuggingFace:
+		return "huggingface"
+	default:
+		return fmt.Sprintf("ModelType(%d)", int(t))
+	}
+}
+
+// MarshalText encodes t by name, so configs read "model_type": "huggingface".
+func (t ModelType) MarshalText() ([]byte, error) {
+	switch t {
+	case ModelTypePyTorch, ModelTypeHuggingFace:
+		return []byte(t.String()), nil
+	default:
+		return nil, fmt.Errorf("gliner2: invalid model type %d", int(t))
+	}
+}
+
+// UnmarshalText accepts "pytorch" or "huggingface".
+func (t *ModelType) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "pytorch":
+		*t = ModelTypePyTorch
+	case "huggingface":
+		*t = ModelTypeHuggingFace
+	default:
+		return fmt.Errorf("gliner2: unknown model type %q (want pytorch or huggingface)", b)
+	}
+	return nil
+}
+
 // Entity is a span extracted for an entity task. Char offsets index into the
 // input text; token offsets index into the model's sub-word tokenization.
 type Entity struct {
@@ -117,11 +156,40 @@ func Structures(name string, fields ...Field) Task {
 
 // Engine is a loaded GLiNER2 model. It is safe to reuse across many Extract
 // calls. It is not guaranteed safe for concurrent Extract calls on the same
-// Engine; serialize calls or use one Engine per goroutine. Call Close to free it.
+// Engine; serialize calls or use one Engine per goroutine. Close may be called
+// concurrently with Extract: it waits for in-flight calls before freeing the
+// native engine. An Engine that becomes unreachable without Close is freed by a
+// finalizer, which logs the leak.
 type Engine struct {
-	ptr unsafe.Pointer
+	repo string // for leak reports
+
+	mu       sync.Mutex
+	idle     *sync.Cond // signalled when inflight drops to zero; created lazily
+	ptr      unsafe.Pointer
+	inflight int
+	closing  bool
 }
 
+var (
+	// ErrClosed is returned by calls on an Engine that is closed or closing.
+	ErrClosed = errors.New("gliner2: engine is closed")
+	// ErrBusy is returned by TryClose while calls are still in flight.
+	ErrBusy = errors.New("gliner2: engine is busy")
+	// ErrInvalidTasks is wrapped by Extract errors for a task list it cannot
+	// run at all, such as an empty one.
+	ErrInvalidTasks = errors.New("gliner2: invalid tasks")
+	// ErrUnsupported is wrapped by Extract errors for tasks or options the
+	// loaded native library does not support (see Capabilities).
+	ErrUnsupported = errors.New("gliner2: not supported by the native library")
+)
+
+// Native entry points used by Engine, swapped out in tests so the lifecycle
+// logic can be exercised without the library.
+var (
+	nativeExtract = cExtract
+	nativeFree    = cFreeEngine
+)
+
 // New loads a GLiNER2 engine from a Hugging Face repo (downloading weights on
 // first use). subfolder selects a variant within the repo (e.g. "fp32_v2",
 // "fp16_v2"); pass "" for the repo root.
@@ -142,11 +210,20 @@ func New(repoID, subfolder string, mt ModelType) (*Engine, error) {
 		defer C.free(unsafe.Pointer(cSub))
 	}
 
+	// The last error is thread-local on the Rust side; read it on the same thread.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
 	ptr := C._g2_call_new(fnNew, cRepo, cSub, C.int(mt))
 	if ptr == nil {
 		return nil, fmt.Errorf("gliner2: load %q: %s", repoID, orUnknown(lastError()))
 	}
-	return &Engine{ptr: ptr}, nil
+	return newEngine(repoID, ptr), nil
+}
+
+func newEngine(repo string, ptr unsafe.Pointer) *Engine {
+	e := &Engine{repo: repo, ptr: ptr}
+	runtime.SetFinalizer(e, (*Engine).finalize)
+	return e
 }
 
 // NewFromHuggingFace is New with ModelTypeHuggingFace — the common ONNX path.
@@ -158,11 +235,11 @@ func NewFromHuggingFace(repoID, subfolder string) (*Engine, error) {
 // span/label confidence cutoff (e.g. 0.5); flatNER, when true, forbids
 // overlapping entity spans (greedy non-overlap) and otherwise allows them.
 func (e *Engine) Extract(text string, tasks []Task, threshold float32, flatNER bool) (*Result, error) {
-	if e == nil || e.ptr == nil {
-		return nil, fmt.Errorf("gliner2: engine is closed")
-	}
 	if len(tasks) == 0 {
-		return nil, fmt.Errorf("gliner2: at least one task is required")
+		return nil, fmt.Errorf("%w: at least one task is required", ErrInvalidTasks)
+	}
+	if err := checkTasks(tasks, flatNER); err != nil {
+		return nil, err
 	}
 
 	tasksJSON, err := json.Marshal(tasks)
@@ -170,9 +247,111 @@ func (e *Engine) Extract(text string, tasks []Task, threshold float32, flatNER b
 		return nil, fmt.Errorf("gliner2: marshal tasks: %w", err)
 	}
 
+	ptr, err := e.acquire()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := nativeExtract(ptr, text, string(tasksJSON), threshold, flatNER)
+	e.release()
+	if err != nil {
+		return nil, err
+	}
+
+	var out Result
+	if err := json.Unmarshal([]byte(raw), &out); err != nil {
+		return nil, fmt.Errorf("gliner2: decode result: %w", err)
+	}
+	return &out, nil
+}
+
+// acquire pins the native engine for one call; pair it with release.
+func (e *Engine) acquire() (unsafe.Pointer, error) {
+	if e == nil {
+		return nil, ErrClosed
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ptr == nil || e.closing {
+		return nil, ErrClosed
+	}
+	e.inflight++
+	return e.ptr, nil
+}
+
+func (e *Engine) release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.inflight--
+	if e.inflight == 0 && e.idle != nil {
+		e.idle.Broadcast()
+	}
+}
+
+// Close frees the engine, first waiting for in-flight calls to return; calls
+// made after Close starts fail with ErrClosed. Safe to call more than once.
+func (e *Engine) Close() {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ptr == nil {
+		return
+	}
+	e.closing = true
+	if e.idle == nil {
+		e.idle = sync.NewCond(&e.mu)
+	}
+	for e.inflight > 0 {
+		e.idle.Wait()
+	}
+	// Another Close (or a TryClose) may have freed it while we waited.
+	if e.ptr == nil {
+		return
+	}
+	e.freeLocked()
+}
+
+// TryClose is Close without waiting: it returns ErrBusy, leaving the engine
+// open, if any call is in flight.
+func (e *Engine) TryClose() error {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ptr == nil {
+		return nil
+	}
+	if e.inflight > 0 {
+		return ErrBusy
+	}
+	e.freeLocked()
+	return nil
+}
+
+func (e *Engine) freeLocked() {
+	nativeFree(e.ptr)
+	e.ptr = nil
+	runtime.SetFinalizer(e, nil)
+}
+
+// finalize frees an engine that was dropped without Close. No call can be in
+// flight: an Engine is reachable for as long as one of its methods runs.
+func (e *Engine) finalize() {
+	if e.ptr == nil {
+		return
+	}
+	log.Printf("gliner2: engine %q was garbage-collected without Close; freeing its native memory", e.repo)
+	nativeFree(e.ptr)
+	e.ptr = nil
+}
+
+// cExtract calls gliner2_extract and returns the result JSON.
+func cExtract(ptr unsafe.Pointer, text, tasksJSON string, threshold float32, flatNER bool) (string, error) {
 	cText := C.CString(text)
 	defer C.free(unsafe.Pointer(cText))
-	cTasks := C.CString(string(tasksJSON))
+	cTasks := C.CString(tasksJSON)
 	defer C.free(unsafe.Pointer(cTasks))
 
 	flat := C.int(0)
@@ -180,26 +359,19 @@ func (e *Engine) Extract(text string, tasks []Task, threshold float32, flatNER b
 		flat = 1
 	}
 
-	cRes := C._g2_call_extract(fnExtract, e.ptr, cText, cTasks, C.float(threshold), flat)
+	// The last error is thread-local on the Rust side; read it on the same thread.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	cRes := C._g2_call_extract(fnExtract, ptr, cText, cTasks, C.float(threshold), flat)
 	if cRes == nil {
-		return nil, fmt.Errorf("gliner2: extract: %s", orUnknown(lastError()))
+		return "", fmt.Errorf("gliner2: extract: %s", orUnknown(lastError()))
 	}
 	defer C._g2_call_free_string(fnFreeString, cRes)
-
-	var out Result
-	if err := json.Unmarshal([]byte(C.GoString(cRes)), &out); err != nil {
-		return nil, fmt.Errorf("gliner2: decode result: %w", err)
-	}
-	return &out, nil
+	return C.GoString(cRes), nil
 }
 
-// Close frees the engine. After Close the Engine must not be used. Safe to call
-// more than once.
-func (e *Engine) Close() {
-	if e != nil && e.ptr != nil {
-		C._g2_call_free_engine(fnFreeEngine, e.ptr)
-		e.ptr = nil
-	}
+func cFreeEngine(ptr unsafe.Pointer) {
+	C._g2_call_free_engine(fnFreeEngine, ptr)
 }
 
 func orUnknown(s string) string {
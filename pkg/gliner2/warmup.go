@@ -0,0 +1,55 @@
+package gliner2
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// warmupTexts are representative inputs of increasing length, so ONNX Runtime
+// initializes its sessions and optimizes for more than one sequence shape
+// before real traffic arrives.
+var warmupTexts = []string{
+	"Warmup.",
+	"Mario Rossi works at Apple in Cupertino and met Tim Cook in Milan last week.",
+	strings.Repeat("The quick brown fox from Acme Corp jumps over the lazy dog near Paris. ", 24),
+}
+
+// defaultWarmupSchema exercises each of the entity, relation and
+// classification heads that the loaded library supports, so Warmup does not
+// fail on a build without the optional ones.
+func defaultWarmupSchema() []Task {
+	var schema []Task
+	if slices.Contains(capabilities, CapEntities) {
+		schema = append(schema, Entities("person", "organization", "location"))
+	}
+	if slices.Contains(capabilities, CapRelations) {
+		schema = append(schema, Relations("works_at", "head", "tail"))
+	}
+	if slices.Contains(capabilities, CapClassifications) {
+		schema = append(schema, Classifications("sentiment", "positive", "negative", "neutral"))
+	}
+	return schema
+}
+
+// Warmup runs a few dummy extractions with schema (a representative default
+// when empty) so the first real Extract does not pay for ONNX session setup
+// and graph optimization. ctx is checked between runs; a single run is not
+// interruptible. Call it after New and before routing traffic to the engine.
+func (e *Engine) Warmup(ctx context.Context, schema []Task) error {
+	if len(schema) == 0 {
+		if schema = defaultWarmupSchema(); len(schema) == 0 {
+			return nil
+		}
+	}
+	for _, text := range warmupTexts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := e.Extract(text, schema, 0.5, false); err != nil {
+			return fmt.Errorf("gliner2: warmup: %w", err)
+		}
+	}
+	return nil
+}
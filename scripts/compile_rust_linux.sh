@@ -25,7 +25,8 @@ cd "$PROJECT_ROOT/gline_binding"
 # Let's support standard cargo build for host architecture if no args.
 
 echo "🐧 Building for Linux..."
-cargo build --release
+# --locked: build exactly the dependency set in the committed Cargo.lock.
+cargo build --release --locked
 
 # Determine Arch
 ARCH=$(uname -m)